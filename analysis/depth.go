@@ -0,0 +1,177 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+// clickable determines if a type of link can be followed by a visitor,
+// unlike links in headers or hreflang annotations
+func clickable(linkType string) bool {
+	switch linkType {
+	case domain.LinkHeader, domain.LinkHreflang:
+		return false
+	}
+
+	return true
+}
+
+// ClickDepths finds the fewest links a visitor must follow from the
+// target to reach each crawled page, by a breadth first search of the
+// link graph. The depth recorded while crawling also counts seeds and
+// links which can't be clicked, so may differ. Pages which can't be
+// reached from the target, such as those only listed in sitemaps,
+// have no click depth.
+func ClickDepths(target *url.URL, pages []*domain.Page) map[string]int {
+	index := make(map[string]*domain.Page)
+	for _, p := range pages {
+		if p != nil && p.Url != nil {
+			index[p.Url.String()] = p
+		}
+	}
+
+	depths := make(map[string]int)
+	queue := make([]string, 0)
+	visit := func(u string, depth int) {
+		if _, ok := depths[u]; ok {
+			return
+		}
+		if _, ok := index[u]; ok {
+			depths[u] = depth
+			queue = append(queue, u)
+		}
+	}
+
+	// Start from the target, or where it redirected to
+	visit(target.String(), 0)
+	for _, p := range pages {
+		if p != nil && p.Url != nil && p.Source == domain.SourceTarget {
+			visit(p.Url.String(), 0)
+		}
+	}
+
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		for _, l := range index[u].Links {
+			if clickable(l.Type) {
+				visit(l.Target.String(), depths[u]+1)
+			}
+		}
+	}
+
+	return depths
+}
+
+// DepthReport lists the pages which are hard to reach from the target
+type DepthReport struct {
+	MaxDepth int `json:"max_depth"`
+
+	// Deep pages are more than the maximum click depth from the target
+	Deep []*PageDepth `json:"deep"`
+
+	// Unreachable pages were crawled, but can't be reached from the target
+	Unreachable []string `json:"unreachable"`
+
+	// Orphans are listed, eg. in a sitemap, but no crawled page links to them
+	Orphans []string `json:"orphans"`
+}
+
+// PageDepth is the click depth of a page, along with the depth
+// it was recorded at while crawling
+type PageDepth struct {
+	Url        string `json:"url"`
+	ClickDepth int    `json:"click_depth"`
+	Depth      int    `json:"depth"`
+}
+
+// AnalyseDepth reports the crawled pages more than maxDepth clicks from
+// the target, or which can't be reached from it, and the listed URLs,
+// eg. from sitemaps, which no other crawled page links to
+func AnalyseDepth(target *url.URL, pages []*domain.Page, maxDepth int, listed []string) *DepthReport {
+	r := &DepthReport{
+		MaxDepth:    maxDepth,
+		Deep:        make([]*PageDepth, 0),
+		Unreachable: make([]string, 0),
+		Orphans:     make([]string, 0),
+	}
+
+	depths := ClickDepths(target, pages)
+	linked := make(map[string]bool)
+	for _, p := range pages {
+		if p == nil || p.Url == nil {
+			continue
+		}
+
+		for _, l := range p.Links {
+			if clickable(l.Type) && l.Target.String() != p.Url.String() {
+				linked[l.Target.String()] = true
+			}
+		}
+
+		// Only pages crawled successfully have links to follow
+		if p.Outcome != "" && p.Outcome != domain.OutcomeOk {
+			continue
+		}
+		d, ok := depths[p.Url.String()]
+		switch {
+		case !ok:
+			r.Unreachable = append(r.Unreachable, p.Url.String())
+		case d > maxDepth:
+			r.Deep = append(r.Deep, &PageDepth{
+				Url:        p.Url.String(),
+				ClickDepth: d,
+				Depth:      p.Depth,
+			})
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, u := range listed {
+		if !linked[u] && !seen[u] && u != target.String() {
+			r.Orphans = append(r.Orphans, u)
+		}
+		seen[u] = true
+	}
+
+	sort.Slice(r.Deep, func(i, j int) bool {
+		if r.Deep[i].ClickDepth != r.Deep[j].ClickDepth {
+			return r.Deep[i].ClickDepth > r.Deep[j].ClickDepth
+		}
+		return r.Deep[i].Url < r.Deep[j].Url
+	})
+	sort.Strings(r.Unreachable)
+	sort.Strings(r.Orphans)
+
+	return r
+}
+
+// WriteText writes a human readable report of hard to reach pages
+func (r *DepthReport) WriteText(w io.Writer) error {
+	lines := []string{fmt.Sprintf("%v pages more than %v clicks deep:", len(r.Deep), r.MaxDepth)}
+	for _, d := range r.Deep {
+		lines = append(lines, fmt.Sprintf("\t%s\t%v", d.Url, d.ClickDepth))
+	}
+
+	lines = append(lines, "", fmt.Sprintf("%v pages unreachable from the target:", len(r.Unreachable)))
+	for _, u := range r.Unreachable {
+		lines = append(lines, "\t"+u)
+	}
+
+	lines = append(lines, "", fmt.Sprintf("%v orphan pages:", len(r.Orphans)))
+	for _, u := range r.Orphans {
+		lines = append(lines, "\t"+u)
+	}
+
+	for _, l := range lines {
+		if _, err := fmt.Fprintln(w, l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
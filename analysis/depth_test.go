@@ -0,0 +1,85 @@
+package analysis
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+func u(path string) *url.URL {
+	return &url.URL{Scheme: "http", Host: "example.com", Path: path}
+}
+
+func page(path string, depth int, links ...string) *domain.Page {
+	p := &domain.Page{
+		Url:     u(path),
+		Depth:   depth,
+		Outcome: domain.OutcomeOk,
+	}
+	for _, l := range links {
+		p.Links = append(p.Links, &domain.Link{Source: p.Url, Target: u(l), Type: domain.LinkAnchor})
+	}
+	return p
+}
+
+func TestClickDepths(t *testing.T) {
+	pages := []*domain.Page{
+		page("/", 0, "/a", "/b"),
+		page("/a", 1, "/c"),
+		// Recorded deeper than it is, as crawls race
+		page("/b", 1, "/d"),
+		page("/c", 2, "/d"),
+		page("/d", 3),
+		page("/seeded", 0, "/d"),
+	}
+	pages[0].Source = domain.SourceTarget
+	pages[5].Source = domain.SourceSitemap
+
+	// Links in headers can't be clicked
+	pages[0].Links = append(pages[0].Links, &domain.Link{Target: u("/seeded"), Type: domain.LinkHeader})
+
+	assert.Equal(t, map[string]int{
+		"http://example.com/":  0,
+		"http://example.com/a": 1,
+		"http://example.com/b": 1,
+		"http://example.com/c": 2,
+		"http://example.com/d": 2,
+	}, ClickDepths(u("/"), pages))
+}
+
+func TestAnalyseDepth(t *testing.T) {
+	pages := []*domain.Page{
+		page("/", 0, "/a", "/"),
+		page("/a", 1, "/b"),
+		page("/b", 2, "/c"),
+		page("/c", 3),
+		page("/seeded", 0, "/seeded"),
+		{Url: u("/gone"), Outcome: domain.OutcomeError},
+	}
+
+	r := AnalyseDepth(u("/"), pages, 1, []string{
+		"http://example.com/",
+		"http://example.com/c",
+		"http://example.com/seeded",
+		"http://example.com/seeded",
+		"http://example.com/unknown",
+	})
+
+	assert.Equal(t, []*PageDepth{
+		{Url: "http://example.com/c", ClickDepth: 3, Depth: 3},
+		{Url: "http://example.com/b", ClickDepth: 2, Depth: 2},
+	}, r.Deep)
+	assert.Equal(t, []string{"http://example.com/seeded"}, r.Unreachable)
+
+	// Links to a page from itself don't count
+	assert.Equal(t, []string{"http://example.com/seeded", "http://example.com/unknown"}, r.Orphans)
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.WriteText(&buf))
+	assert.Contains(t, buf.String(), "2 pages more than 1 clicks deep:\n\thttp://example.com/c\t3\n")
+	assert.Contains(t, buf.String(), "2 orphan pages:\n")
+}
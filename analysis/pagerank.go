@@ -0,0 +1,115 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+const (
+	// damping is the probability a visitor follows a link, rather
+	// than jumping to a random page, as in the original PageRank
+	damping = 0.85
+
+	// PageRank is iterated until it changes by less than tolerance
+	maxIterations = 100
+	tolerance     = 1e-9
+)
+
+// PageMetrics describe how a page is linked within a site
+type PageMetrics struct {
+	// Inlinks and Outlinks count the distinct crawled pages linking
+	// to and from the page, ignoring links to itself
+	Inlinks  int `json:"inlinks"`
+	Outlinks int `json:"outlinks"`
+
+	// PageRank of the page within the site's internal link graph,
+	// where the PageRank of every page sums to 1
+	PageRank float64 `json:"pagerank"`
+}
+
+// LinkMetrics computes the metrics of each page crawled successfully,
+// over the graph of links between these pages
+func LinkMetrics(pages []*domain.Page) map[string]*PageMetrics {
+	ret := make(map[string]*PageMetrics)
+
+	// Nodes, and the sources linking to each, are sorted so sums are
+	// made in the same order every time, whatever order pages are in
+	nodes := make([]string, 0)
+	for _, p := range pages {
+		if p == nil || p.Url == nil || (p.Outcome != "" && p.Outcome != domain.OutcomeOk) {
+			continue
+		}
+		if _, ok := ret[p.Url.String()]; !ok {
+			ret[p.Url.String()] = &PageMetrics{}
+			nodes = append(nodes, p.Url.String())
+		}
+	}
+	if len(nodes) == 0 {
+		return ret
+	}
+	sort.Strings(nodes)
+
+	// Distinct internal links, by target
+	sources := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, p := range pages {
+		if p == nil || p.Url == nil || ret[p.Url.String()] == nil {
+			continue
+		}
+		source := p.Url.String()
+		for _, l := range p.Links {
+			target := l.Target.String()
+			if source == target || ret[target] == nil || seen[source+" "+target] {
+				continue
+			}
+			seen[source+" "+target] = true
+
+			sources[target] = append(sources[target], source)
+			ret[source].Outlinks++
+			ret[target].Inlinks++
+		}
+	}
+	for _, s := range sources {
+		sort.Strings(s)
+	}
+
+	n := float64(len(nodes))
+	rank := make(map[string]float64)
+	for _, u := range nodes {
+		rank[u] = 1 / n
+	}
+
+	for i := 0; i < maxIterations; i++ {
+		// Pages without links share their rank with every page
+		dangling := 0.0
+		for _, u := range nodes {
+			if ret[u].Outlinks == 0 {
+				dangling += rank[u]
+			}
+		}
+
+		next := make(map[string]float64)
+		delta := 0.0
+		for _, u := range nodes {
+			r := (1-damping)/n + damping*dangling/n
+			for _, s := range sources[u] {
+				r += damping * rank[s] / float64(ret[s].Outlinks)
+			}
+			next[u] = r
+			delta += math.Abs(r - rank[u])
+		}
+
+		rank = next
+		if delta < tolerance {
+			break
+		}
+	}
+
+	for _, u := range nodes {
+		ret[u].PageRank = rank[u]
+	}
+
+	return ret
+}
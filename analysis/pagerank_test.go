@@ -0,0 +1,68 @@
+package analysis
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+func TestLinkMetrics(t *testing.T) {
+	pages := []*domain.Page{
+		page("/", 0, "/popular", "/a", "/a", "/"),
+		page("/a", 1, "/popular"),
+		page("/b", 1, "/popular", "/missing"),
+		page("/popular", 1, "/"),
+		page("/orphan", 0),
+		{Url: u("/missing"), Outcome: domain.OutcomeError},
+	}
+	pages[0].Links = append(pages[0].Links, &domain.Link{
+		Target: &url.URL{Scheme: "http", Host: "github.com", Path: "/"},
+		Type:   domain.LinkAnchor,
+	})
+
+	m := LinkMetrics(pages)
+	assert.Len(t, m, 5)
+
+	// Only distinct links between crawled pages count
+	assert.Equal(t, 1, m["http://example.com/"].Inlinks)
+	assert.Equal(t, 2, m["http://example.com/"].Outlinks)
+	assert.Equal(t, 3, m["http://example.com/popular"].Inlinks)
+	assert.Equal(t, 1, m["http://example.com/b"].Outlinks)
+	assert.Equal(t, 0, m["http://example.com/orphan"].Inlinks)
+	assert.Nil(t, m["http://example.com/missing"])
+
+	sum := 0.0
+	for _, pm := range m {
+		sum += pm.PageRank
+	}
+	assert.InDelta(t, 1.0, sum, 1e-6)
+
+	// The most linked to page is favoured, and pages nothing links to least
+	assert.True(t, m["http://example.com/popular"].PageRank > m["http://example.com/"].PageRank)
+	assert.True(t, m["http://example.com/"].PageRank > m["http://example.com/a"].PageRank)
+	assert.True(t, m["http://example.com/a"].PageRank > m["http://example.com/b"].PageRank)
+	assert.InDelta(t, m["http://example.com/b"].PageRank, m["http://example.com/orphan"].PageRank, 1e-9)
+}
+
+func TestLinkMetricsOrder(t *testing.T) {
+	pages := []*domain.Page{
+		page("/", 0, "/a", "/b", "/c"),
+		page("/a", 1, "/b", "/c"),
+		page("/b", 1, "/c", "/"),
+		page("/c", 1, "/a"),
+	}
+	m := LinkMetrics(pages)
+
+	// The same PageRank exactly, whatever order pages are crawled in
+	reversed := []*domain.Page{pages[3], pages[2], pages[1], pages[0]}
+	for u, pm := range LinkMetrics(reversed) {
+		assert.Equal(t, m[u].PageRank, pm.PageRank, u)
+	}
+}
+
+func TestLinkMetricsEmpty(t *testing.T) {
+	assert.Empty(t, LinkMetrics(nil))
+}
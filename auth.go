@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Environment variables, or keys in a credentials file,
+	// which credentials are read from
+	usernameKey = "KRAKEN_USERNAME"
+	passwordKey = "KRAKEN_PASSWORD"
+	tokenKey    = "KRAKEN_TOKEN"
+)
+
+var (
+	MissingCredentials     = errors.New("Credentials have not been provided")
+	InvalidCredentialsLine = errors.New("Credentials file lines must be in the form KEY=value")
+)
+
+// logoutPattern matches URLs which would end an authenticated session,
+// eg. /logout, /sign-out or /account?action=logoff, as whole path
+// segments or query values so /catalog-outlet isn't mistaken for one
+var logoutPattern = regexp.MustCompile(`(?i)(^|/|=)(log|sign)[-_]?(out|off)($|/|&|\?|\.)`)
+
+// Authenticator logs in to a site and adds credentials to requests
+type Authenticator interface {
+	// Login is called once before crawling, with a client which will
+	// keep any cookies set so they are sent with subsequent requests
+	Login(client *http.Client) error
+
+	// Apply adds credentials to a request
+	Apply(req *http.Request)
+}
+
+// BasicAuth sends a username and password with every request
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (a *BasicAuth) Login(client *http.Client) error {
+	return nil
+}
+
+func (a *BasicAuth) Apply(req *http.Request) {
+	req.SetBasicAuth(a.Username, a.Password)
+}
+
+// BearerAuth sends a token with every request
+type BearerAuth struct {
+	Token string
+}
+
+func (a *BearerAuth) Login(client *http.Client) error {
+	return nil
+}
+
+func (a *BearerAuth) Apply(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+}
+
+// FormAuth posts credentials to a login form, and relies on the session
+// cookie it sets being sent with every subsequent request
+type FormAuth struct {
+	// LoginUrl the login form is posted to
+	LoginUrl *url.URL
+
+	// Fields posted to the login form, eg. username and password
+	Fields url.Values
+}
+
+func (a *FormAuth) Login(client *http.Client) error {
+	res, err := client.PostForm(a.LoginUrl.String(), a.Fields)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return fmt.Errorf("Login to %s failed with status %v", a.LoginUrl, res.StatusCode)
+	}
+
+	return nil
+}
+
+func (a *FormAuth) Apply(req *http.Request) {}
+
+// Login to the target site using our Authenticator, if we have one.
+// A cookie jar is added to our client if it doesn't have one, so
+// sessions are maintained across requests.
+func (h *HttpFetcher) Login() error {
+	if h.Auth == nil {
+		return nil
+	}
+
+	if h.Client == nil {
+		h.Client = &http.Client{}
+	}
+	if h.Client.Jar == nil {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return err
+		}
+		h.Client.Jar = jar
+	}
+
+	return h.Auth.Login(h.Client)
+}
+
+// isLogoutUrl determines if a URL looks like it would log us out
+func isLogoutUrl(u *url.URL) bool {
+	return logoutPattern.MatchString(u.Path) || logoutPattern.MatchString(u.RawQuery)
+}
+
+// Credentials are read from the environment, or a file of KEY=value lines
+type Credentials map[string]string
+
+// ReadCredentials from the environment, with any values in the
+// specified file taking precedence
+func ReadCredentials(filename string) (Credentials, error) {
+	creds := make(Credentials)
+	for _, k := range []string{usernameKey, passwordKey, tokenKey} {
+		if v := os.Getenv(k); v != "" {
+			creds[k] = v
+		}
+	}
+
+	if filename == "" {
+		return creds, nil
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip blank lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return nil, InvalidCredentialsLine
+		}
+		creds[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+	}
+
+	return creds, scanner.Err()
+}
+
+// NewAuthenticator builds an Authenticator of the specified type (basic,
+// bearer or form) from our credentials. Form logins post the username
+// and password as the specified form fields to loginUrl.
+func NewAuthenticator(method string, creds Credentials, loginUrl *url.URL, userField, passField string) (Authenticator, error) {
+	switch method {
+	case "":
+		return nil, nil
+	case "basic":
+		if creds[usernameKey] == "" {
+			return nil, MissingCredentials
+		}
+		return &BasicAuth{
+			Username: creds[usernameKey],
+			Password: creds[passwordKey],
+		}, nil
+	case "bearer":
+		if creds[tokenKey] == "" {
+			return nil, MissingCredentials
+		}
+		return &BearerAuth{
+			Token: creds[tokenKey],
+		}, nil
+	case "form":
+		if creds[usernameKey] == "" {
+			return nil, MissingCredentials
+		}
+		if loginUrl == nil {
+			return nil, errors.New("Form login requires a login URL")
+		}
+		return &FormAuth{
+			LoginUrl: loginUrl,
+			Fields: url.Values{
+				userField: []string{creds[usernameKey]},
+				passField: []string{creds[passwordKey]},
+			},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown authentication method '%s'", method)
+}
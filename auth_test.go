@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/crawler"
+)
+
+func TestBasicAndBearerAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		switch {
+		case ok && user == "kraken" && pass == "release":
+		case r.Header.Get("Authorization") == "Bearer s3cret":
+		default:
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `<a href="/private">Private</a>`)
+	}))
+	defer server.Close()
+
+	testCases := []Authenticator{
+		&BasicAuth{Username: "kraken", Password: "release"},
+		&BearerAuth{Token: "s3cret"},
+	}
+
+	for _, auth := range testCases {
+		f := &HttpFetcher{Auth: auth, AuthHosts: []string{strToUrl(server.URL).Host}}
+		assert.Nil(t, f.Login())
+
+		page, err := f.Fetch(strToUrl(server.URL + "/"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{server.URL + "/private"}, linksToStrings(page.Links))
+	}
+}
+
+func TestAuthOnlySentToAuthHosts(t *testing.T) {
+	var sent string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = r.Header.Get("Authorization")
+		fmt.Fprint(w, `<a href="/logout">Log out</a>`)
+	}))
+	defer other.Close()
+
+	f := &HttpFetcher{
+		Auth:      &BearerAuth{Token: "s3cret"},
+		AuthHosts: []string{"example.com"},
+	}
+
+	// Other sites, eg. listed in robots.txt or sitemaps, get no credentials
+	_, err := f.Fetch(strToUrl(other.URL + "/"))
+	assert.Nil(t, err)
+	assert.Empty(t, sent)
+
+	// Nor do we need to worry about logging out of them
+	_, err = f.Fetch(strToUrl(other.URL + "/logout"))
+	assert.Nil(t, err)
+	assert.Empty(t, sent)
+}
+
+func TestFormAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			if r.PostFormValue("email") != "kraken" || r.PostFormValue("pass") != "release" {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		case "/logout":
+			t.Error("Logout link was followed")
+		default:
+			if c, err := r.Cookie("session"); err != nil || c.Value != "abc" {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `<a href="/private">Private</a><a href="/logout">Log out</a>`)
+		}
+	}))
+	defer server.Close()
+
+	creds := Credentials{usernameKey: "kraken", passwordKey: "release"}
+	auth, err := NewAuthenticator("form", creds, strToUrl(server.URL+"/login"), "email", "pass")
+	assert.Nil(t, err)
+
+	f := &HttpFetcher{Auth: auth, AuthHosts: []string{strToUrl(server.URL).Host}}
+	assert.Nil(t, f.Login())
+
+	page, err := f.Fetch(strToUrl(server.URL + "/"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{server.URL + "/private", server.URL + "/logout"}, linksToStrings(page.Links))
+
+	_, err = f.Fetch(strToUrl(server.URL + "/logout"))
+	assert.Equal(t, crawler.LogoutSkipped, err)
+
+	// Bad credentials should fail to log in
+	creds[passwordKey] = "wrong"
+	auth, _ = NewAuthenticator("form", creds, strToUrl(server.URL+"/login"), "email", "pass")
+	f = &HttpFetcher{Auth: auth}
+	assert.NotNil(t, f.Login())
+}
+
+func TestIsLogoutUrl(t *testing.T) {
+	testCases := map[string]bool{
+		"http://example.com/logout":                true,
+		"http://example.com/users/sign_out":        true,
+		"http://example.com/Log-Off":               true,
+		"http://example.com/account?action=logout": true,
+		"http://example.com/logout.php":            true,
+		"http://example.com/?a=1&do=signoff&b=2":   true,
+		"http://example.com/":                      false,
+		"http://example.com/blog/logging-outages":  false,
+		"http://example.com/login":                 false,
+		"http://example.com/catalog-outlet":        false,
+		"http://example.com/catalog_offers":        false,
+		"http://example.com/blog-outreach":         false,
+		"http://example.com/?q=blog-outreach":      false,
+	}
+
+	for tc, expected := range testCases {
+		assert.Equal(t, expected, isLogoutUrl(strToUrl(tc)), tc)
+	}
+}
+
+func TestReadCredentials(t *testing.T) {
+	os.Setenv(usernameKey, "from-env")
+	os.Setenv(passwordKey, "env-password")
+	defer os.Unsetenv(usernameKey)
+	defer os.Unsetenv(passwordKey)
+
+	creds, err := ReadCredentials("")
+	assert.Nil(t, err)
+	assert.Equal(t, "from-env", creds[usernameKey])
+
+	// Values in the file override the environment
+	f, err := ioutil.TempFile("", "kraken")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	fmt.Fprint(f, "# Staging credentials\n\nKRAKEN_USERNAME = from-file\nKRAKEN_TOKEN=abc=123\n")
+	f.Close()
+
+	creds, err = ReadCredentials(f.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, "from-file", creds[usernameKey])
+	assert.Equal(t, "env-password", creds[passwordKey])
+	assert.Equal(t, "abc=123", creds[tokenKey])
+}
+
+func TestNewAuthenticatorErrors(t *testing.T) {
+	login := &url.URL{Scheme: "http", Host: "example.com", Path: "/login"}
+
+	testCases := map[string]Credentials{
+		"basic":  Credentials{},
+		"bearer": Credentials{usernameKey: "kraken"},
+		"form":   Credentials{passwordKey: "release"},
+	}
+
+	for method, creds := range testCases {
+		_, err := NewAuthenticator(method, creds, login, "username", "password")
+		assert.Equal(t, MissingCredentials, err, method)
+	}
+
+	_, err := NewAuthenticator("kerberos", Credentials{}, login, "username", "password")
+	assert.NotNil(t, err)
+}
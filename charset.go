@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/url"
+
+	"code.google.com/p/go.net/html/charset"
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+// sniffLength is how much of a document we examine to determine its
+// charset, matching the HTML5 encoding sniffing algorithm
+const sniffLength = 1024
+
+// read parses a document from body and extracts its links and assets. The
+// document is transcoded to UTF-8 first, using the charset declared in the
+// Content-Type header or a meta tag, or sniffed from the content itself.
+// Relative links are resolved against base.
+func (e *extractor) read(body io.Reader, base *url.URL, header http.Header) (*domain.Page, error) {
+
+	br := bufio.NewReaderSize(body, sniffLength)
+	content, _ := br.Peek(sniffLength)
+	_, name, _ := charset.DetermineEncoding(content, header.Get("Content-Type"))
+
+	utf8, err := charset.NewReaderLabel(name, br)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(utf8)
+	if err != nil {
+		return nil, err
+	}
+	doc.Url = base
+
+	page, err := e.extract(doc, header)
+	if err != nil {
+		return nil, err
+	}
+	page.Charset = name
+
+	return page, nil
+}
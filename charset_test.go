@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadCharsets(t *testing.T) {
+	e := &extractor{}
+	base := strToUrl("http://example.com/")
+
+	testCases := []struct {
+		contentType string
+		body        string
+		charset     string
+		link        string
+	}{
+		// Declared in the Content-Type header
+		{"text/html; charset=ISO-8859-1", "<a href=\"/caf\xe9\">Caf\xe9</a>", "windows-1252", "http://example.com/caf%C3%A9"},
+
+		// Declared in a meta tag
+		{"text/html", "<meta charset=\"Shift_JIS\"><a href=\"/\x93\xfa\x96\x7b\">\x93\xfa\x96\x7b</a>", "shift_jis", "http://example.com/%E6%97%A5%E6%9C%AC"},
+		{"", "<meta http-equiv=\"Content-Type\" content=\"text/html; charset=windows-1252\"><a href=\"/\x80\">Euro</a>", "windows-1252", "http://example.com/%E2%82%AC"},
+
+		// Sniffed from content which isn't valid UTF-8
+		{"text/html", "<a href=\"/na\xefve\">Na\xefve</a>", "windows-1252", "http://example.com/na%C3%AFve"},
+
+		// UTF-8 is left alone
+		{"text/html; charset=utf-8", "<a href=\"/café\">Café</a>", "utf-8", "http://example.com/caf%C3%A9"},
+		{"", "<a href=\"/café\">Café</a>", "utf-8", "http://example.com/caf%C3%A9"},
+	}
+
+	for _, tc := range testCases {
+		header := http.Header{}
+		if tc.contentType != "" {
+			header.Set("Content-Type", tc.contentType)
+		}
+
+		page, err := e.read(strings.NewReader(tc.body), base, header)
+		assert.Nil(t, err, tc.body)
+		assert.Equal(t, tc.charset, page.Charset, tc.body)
+		assert.Equal(t, []string{tc.link}, linksToStrings(page.Links), tc.body)
+	}
+}
@@ -0,0 +1,87 @@
+package check
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	InvalidBaselineLine = errors.New("Baseline lines must be in the form: rule url [target]")
+)
+
+// Baseline of known violations, which don't fail a check. Each line of
+// a baseline file is a rule, a page URL and optionally a target URL,
+// separated by whitespace, where * matches anything and a trailing *
+// matches any suffix. Blank lines and lines starting with # are ignored.
+type Baseline struct {
+	entries [][]string
+}
+
+// ReadBaseline parses a baseline file
+func ReadBaseline(r io.Reader) (*Baseline, error) {
+	b := &Baseline{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, InvalidBaselineLine
+		}
+		b.entries = append(b.entries, fields)
+	}
+
+	return b, scanner.Err()
+}
+
+// Matches determines if a violation is in the baseline
+func (b *Baseline) Matches(v *Violation) bool {
+	if b == nil {
+		return false
+	}
+
+	for _, e := range b.entries {
+		target := "*"
+		if len(e) == 3 {
+			target = e[2]
+		}
+		if baselineMatch(e[0], v.Rule) && baselineMatch(e[1], v.Url) && baselineMatch(target, v.Target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// baselineMatch matches a value exactly, or by prefix if
+// the pattern ends with *
+func baselineMatch(pattern, value string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))
+	}
+
+	return pattern == value
+}
+
+// WriteBaseline writes a baseline of the specified violations,
+// so that they don't fail future checks
+func WriteBaseline(w io.Writer, violations []*Violation) error {
+	for _, v := range violations {
+		line := v.Rule + " " + v.Url
+		if v.Target != "" {
+			line += " " + v.Target
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
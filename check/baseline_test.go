@@ -0,0 +1,59 @@
+package check
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadBaseline(t *testing.T) {
+	b, err := ReadBaseline(strings.NewReader(`
+# Known problems, fixed in the next release
+broken-link http://example.com/ http://example.com/missing
+missing-title   http://example.com/legacy/*
+`))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		v       *Violation
+		matches bool
+	}{
+		{&Violation{Rule: "broken-link", Url: "http://example.com/", Target: "http://example.com/missing"}, true},
+		{&Violation{Rule: "broken-link", Url: "http://example.com/", Target: "http://example.com/gone"}, false},
+		{&Violation{Rule: "broken-link", Url: "http://example.com/about", Target: "http://example.com/missing"}, false},
+		{&Violation{Rule: "missing-title", Url: "http://example.com/legacy/page"}, true},
+		{&Violation{Rule: "missing-title", Url: "http://example.com/new"}, false},
+		{&Violation{Rule: "redirect-loop", Url: "http://example.com/legacy/page"}, false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.matches, b.Matches(tc.v), "%+v", tc.v)
+	}
+
+	// Without a baseline nothing matches
+	var none *Baseline
+	assert.False(t, none.Matches(testCases[0].v))
+
+	_, err = ReadBaseline(strings.NewReader("broken-link\n"))
+	assert.Equal(t, InvalidBaselineLine, err)
+}
+
+func TestWriteBaseline(t *testing.T) {
+	violations := []*Violation{
+		{Rule: "broken-link", Url: "http://example.com/", Target: "http://example.com/missing"},
+		{Rule: "redirect-loop", Url: "http://example.com/loop"},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteBaseline(&buf, violations))
+	assert.Equal(t, "broken-link http://example.com/ http://example.com/missing\n"+
+		"redirect-loop http://example.com/loop\n", buf.String())
+
+	// Written baselines match the violations they were written from
+	b, err := ReadBaseline(&buf)
+	assert.NoError(t, err)
+	for _, v := range violations {
+		assert.True(t, b.Matches(v))
+	}
+}
@@ -0,0 +1,246 @@
+package check
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mattheath/kraken/domain"
+	"github.com/mattheath/kraken/sitemap"
+)
+
+// Rule crawled pages can be checked against
+type Rule struct {
+	Id          string
+	Description string
+
+	check func(pages []*domain.Page) []*Violation
+}
+
+// Rules available, by id
+var Rules = []*Rule{
+	{"broken-link", "Links must not lead to pages which fail, or respond with a 4xx or 5xx status, nor may pages nothing links to", brokenLinks},
+	{"redirect-loop", "Pages must not redirect in a loop", redirectLoops},
+	{"missing-title", "Pages must have a title", missingTitles},
+	{"hreflang", "Hreflang annotations must have valid language codes and return links", hreflang},
+}
+
+// DefaultRules are checked if none are selected
+const DefaultRules = "broken-link,redirect-loop"
+
+// Violation of a rule by a page, where Target is the URL of
+// the link or annotation at fault, if there is one
+type Violation struct {
+	Rule    string `json:"rule"`
+	Url     string `json:"url"`
+	Target  string `json:"target,omitempty"`
+	Message string `json:"message"`
+
+	// Baselined violations are known, so don't fail a check
+	Baselined bool `json:"baselined,omitempty"`
+}
+
+// Result of checking a crawl
+type Result struct {
+	Rules      []*Rule
+	Pages      []string
+	Violations []*Violation
+}
+
+// SelectRules parses a comma separated list of rule ids, which must
+// all be available. The default rules are selected if none are.
+func SelectRules(selected string) ([]*Rule, error) {
+	if strings.TrimSpace(selected) == "" {
+		selected = DefaultRules
+	}
+
+	ret := make([]*Rule, 0)
+	for _, id := range strings.Split(selected, ",") {
+		id = strings.ToLower(strings.TrimSpace(id))
+		rule := findRule(id)
+		if rule == nil {
+			ids := make([]string, len(Rules))
+			for i, r := range Rules {
+				ids[i] = r.Id
+			}
+			return nil, fmt.Errorf("Unknown rule '%s', must be one of %s", id, strings.Join(ids, ", "))
+		}
+		ret = append(ret, rule)
+	}
+
+	return ret, nil
+}
+
+func findRule(id string) *Rule {
+	for _, r := range Rules {
+		if r.Id == id {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// Run checks the pages fetched during a crawl against each rule,
+// marking violations in the baseline, which may be nil
+func Run(rules []*Rule, pages []*domain.Page, baseline *Baseline) *Result {
+	res := &Result{
+		Rules:      rules,
+		Pages:      make([]string, 0),
+		Violations: make([]*Violation, 0),
+	}
+
+	for _, p := range pages {
+		if fetched(p) {
+			res.Pages = append(res.Pages, p.Url.String())
+		}
+	}
+	sort.Strings(res.Pages)
+
+	for _, r := range rules {
+		violations := r.check(pages)
+		sort.SliceStable(violations, func(i, j int) bool {
+			if violations[i].Url != violations[j].Url {
+				return violations[i].Url < violations[j].Url
+			}
+			return violations[i].Target < violations[j].Target
+		})
+		for _, v := range violations {
+			v.Rule = r.Id
+			v.Baselined = baseline.Matches(v)
+			res.Violations = append(res.Violations, v)
+		}
+	}
+
+	return res
+}
+
+// Failed determines if any violations aren't baselined
+func (r *Result) Failed() bool {
+	return len(r.Failures()) > 0
+}
+
+// Failures are the violations which aren't baselined
+func (r *Result) Failures() []*Violation {
+	ret := make([]*Violation, 0)
+	for _, v := range r.Violations {
+		if !v.Baselined {
+			ret = append(ret, v)
+		}
+	}
+
+	return ret
+}
+
+// WriteText writes a human readable summary of the violations
+// which aren't baselined
+func (r *Result) WriteText(w io.Writer) error {
+	failures := r.Failures()
+	_, err := fmt.Fprintf(w, "%v pages checked, %v violations, %v baselined\n",
+		len(r.Pages), len(failures), len(r.Violations)-len(failures))
+
+	for _, v := range failures {
+		if err != nil {
+			break
+		}
+		_, err = fmt.Fprintf(w, "\t%s\t%s\t%s\n", v.Rule, v.Url, v.Message)
+	}
+
+	return err
+}
+
+// fetched determines if a page was fetched, successfully or not
+func fetched(p *domain.Page) bool {
+	if p == nil || p.Url == nil {
+		return false
+	}
+
+	switch p.Outcome {
+	case "", domain.OutcomeOk, domain.OutcomeError:
+		return true
+	}
+
+	return false
+}
+
+func brokenLinks(pages []*domain.Page) []*Violation {
+	loops := make(map[string]bool)
+	for _, p := range pages {
+		if fetched(p) && p.RedirectLoop {
+			loops[p.Url.String()] = true
+		}
+	}
+
+	ret := make([]*Violation, 0)
+	for _, b := range sitemap.FindBrokenTargets(pages) {
+		// Redirect loops are a rule of their own
+		if loops[b.Url] {
+			continue
+		}
+
+		reason := b.Error
+		if reason == "" {
+			reason = fmt.Sprintf("status %v", b.Status)
+		}
+		for _, l := range b.Links {
+			ret = append(ret, &Violation{
+				Url:     l.Source,
+				Target:  b.Url,
+				Message: fmt.Sprintf("Link to %s is broken: %s", b.Url, reason),
+			})
+		}
+
+		// Pages nothing links to, such as our target or a sitemap
+		// seed, are broken in their own right
+		if len(b.Links) == 0 {
+			ret = append(ret, &Violation{
+				Url:     b.Url,
+				Message: fmt.Sprintf("Page is broken: %s", reason),
+			})
+		}
+	}
+
+	return ret
+}
+
+func redirectLoops(pages []*domain.Page) []*Violation {
+	ret := make([]*Violation, 0)
+	for _, p := range pages {
+		if fetched(p) && p.RedirectLoop {
+			ret = append(ret, &Violation{
+				Url:     p.Url.String(),
+				Message: "Page redirects in a loop",
+			})
+		}
+	}
+
+	return ret
+}
+
+func missingTitles(pages []*domain.Page) []*Violation {
+	ret := make([]*Violation, 0)
+	for _, p := range pages {
+		if fetched(p) && p.Outcome != domain.OutcomeError && p.Status < 300 && strings.TrimSpace(p.Title) == "" {
+			ret = append(ret, &Violation{
+				Url:     p.Url.String(),
+				Message: "Page has no title",
+			})
+		}
+	}
+
+	return ret
+}
+
+func hreflang(pages []*domain.Page) []*Violation {
+	ret := make([]*Violation, 0)
+	for _, i := range sitemap.ValidateHreflang(pages) {
+		ret = append(ret, &Violation{
+			Url:     i.Page.String(),
+			Target:  i.Alternate.String(),
+			Message: fmt.Sprintf("Hreflang %s: %s", i.Lang, i.Problem),
+		})
+	}
+
+	return ret
+}
@@ -0,0 +1,110 @@
+package check
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+func testPages() []*domain.Page {
+	u := func(path string) *url.URL {
+		return &url.URL{Scheme: "http", Host: "example.com", Path: path}
+	}
+
+	return []*domain.Page{
+		{
+			Url:     u("/"),
+			Status:  200,
+			Title:   "Home",
+			Outcome: domain.OutcomeOk,
+			Links: []*domain.Link{
+				{Target: u("/missing"), Type: domain.LinkAnchor, Text: "Missing"},
+				{Target: u("/loop"), Type: domain.LinkAnchor, Text: "Loop"},
+				{Target: u("/untitled"), Type: domain.LinkAnchor},
+			},
+		},
+		{Url: u("/missing"), Status: 404, Outcome: domain.OutcomeOk},
+		{Url: u("/loop"), Outcome: domain.OutcomeError, Error: `Get "http://example.com/loop": Redirect loop`, RedirectLoop: true},
+		{Url: u("/untitled"), Status: 200, Outcome: domain.OutcomeOk},
+		{Url: u("/deep"), Outcome: domain.OutcomeSkipped},
+	}
+}
+
+func TestSelectRules(t *testing.T) {
+	rules, err := SelectRules("")
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+	assert.Equal(t, "broken-link", rules[0].Id)
+	assert.Equal(t, "redirect-loop", rules[1].Id)
+
+	rules, err = SelectRules("Missing-Title, hreflang")
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+	assert.Equal(t, "missing-title", rules[0].Id)
+
+	_, err = SelectRules("broken-link,spelling")
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	rules, _ := SelectRules("broken-link,redirect-loop,missing-title")
+	res := Run(rules, testPages(), nil)
+
+	assert.Equal(t, []string{
+		"http://example.com/",
+		"http://example.com/loop",
+		"http://example.com/missing",
+		"http://example.com/untitled",
+	}, res.Pages)
+
+	// Redirect loops aren't also reported as broken links
+	assert.Equal(t, []*Violation{
+		{
+			Rule:    "broken-link",
+			Url:     "http://example.com/",
+			Target:  "http://example.com/missing",
+			Message: "Link to http://example.com/missing is broken: status 404",
+		},
+		{Rule: "redirect-loop", Url: "http://example.com/loop", Message: "Page redirects in a loop"},
+		{Rule: "missing-title", Url: "http://example.com/untitled", Message: "Page has no title"},
+	}, res.Violations)
+	assert.True(t, res.Failed())
+
+	var buf bytes.Buffer
+	assert.NoError(t, res.WriteText(&buf))
+	assert.Contains(t, buf.String(), "4 pages checked, 3 violations, 0 baselined\n")
+	assert.Contains(t, buf.String(), "\tredirect-loop\thttp://example.com/loop\tPage redirects in a loop\n")
+}
+
+func TestRunBrokenTarget(t *testing.T) {
+	target := &url.URL{Scheme: "http", Host: "example.com", Path: "/"}
+	rules, _ := SelectRules("")
+
+	// Nothing links to our target, but it's still broken
+	res := Run(rules, []*domain.Page{{Url: target, Status: 500, Outcome: domain.OutcomeOk}}, nil)
+	assert.Equal(t, []*Violation{
+		{Rule: "broken-link", Url: "http://example.com/", Message: "Page is broken: status 500"},
+	}, res.Violations)
+	assert.True(t, res.Failed())
+
+	res = Run(rules, []*domain.Page{{Url: target, Outcome: domain.OutcomeError, Error: "no such host"}}, nil)
+	assert.Len(t, res.Violations, 1)
+	assert.True(t, res.Failed())
+}
+
+func TestRunBaselined(t *testing.T) {
+	rules, _ := SelectRules("")
+	baseline := &Baseline{entries: [][]string{
+		{"broken-link", "http://example.com/", "http://example.com/missing"},
+		{"redirect-loop", "*"},
+	}}
+	res := Run(rules, testPages(), baseline)
+
+	assert.Len(t, res.Violations, 2)
+	assert.Empty(t, res.Failures())
+	assert.False(t, res.Failed())
+}
@@ -0,0 +1,84 @@
+package check
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/mattheath/kraken/export"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name      `xml:"testsuites"`
+	Name     string        `xml:"name,attr"`
+	Tests    int           `xml:"tests,attr"`
+	Failures int           `xml:"failures,attr"`
+	Suites   []*junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Cases    []*junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the result as JUnit XML, as read by most CI
+// systems, with a suite for each rule and a case for each page.
+// Pages fail with every violation which isn't baselined.
+func WriteJUnit(w io.Writer, res *Result) error {
+	doc := &junitSuites{
+		Name: "kraken",
+	}
+
+	for _, r := range res.Rules {
+		failures := make(map[string][]*Violation)
+		for _, v := range res.Failures() {
+			if v.Rule == r.Id {
+				failures[v.Url] = append(failures[v.Url], v)
+			}
+		}
+
+		suite := &junitSuite{
+			Name:  r.Id,
+			Tests: len(res.Pages),
+		}
+		for _, u := range res.Pages {
+			c := &junitCase{
+				Name:      u,
+				Classname: r.Id,
+			}
+			if vs := failures[u]; len(vs) > 0 {
+				messages := make([]string, len(vs))
+				for i, v := range vs {
+					messages[i] = v.Message
+				}
+				c.Failure = &junitFailure{
+					Message: vs[0].Message,
+					Type:    r.Id,
+					Text:    strings.Join(messages, "\n"),
+				}
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+		doc.Suites = append(doc.Suites, suite)
+	}
+
+	return export.WriteXML(w, doc)
+}
@@ -0,0 +1,57 @@
+package check
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	rules, _ := SelectRules("")
+	res := Run(rules, testPages(), &Baseline{entries: [][]string{{"redirect-loop", "*"}}})
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteJUnit(&buf, res))
+
+	doc := &junitSuites{}
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), doc))
+	assert.Equal(t, 8, doc.Tests)
+	assert.Equal(t, 1, doc.Failures)
+	assert.Len(t, doc.Suites, 2)
+
+	// Baselined violations don't fail
+	assert.Equal(t, "redirect-loop", doc.Suites[1].Name)
+	assert.Equal(t, 0, doc.Suites[1].Failures)
+
+	broken := doc.Suites[0]
+	assert.Equal(t, 1, broken.Failures)
+	assert.Equal(t, "http://example.com/", broken.Cases[0].Name)
+	assert.Equal(t, "Link to http://example.com/missing is broken: status 404", broken.Cases[0].Failure.Message)
+	assert.Nil(t, broken.Cases[1].Failure)
+}
+
+func TestWriteSARIF(t *testing.T) {
+	rules, _ := SelectRules("")
+	res := Run(rules, testPages(), &Baseline{entries: [][]string{{"redirect-loop", "*"}}})
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteSARIF(&buf, res))
+
+	doc := &sarifLog{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), doc))
+	assert.Equal(t, "2.1.0", doc.Version)
+	assert.Len(t, doc.Runs, 1)
+	assert.Len(t, doc.Runs[0].Tool.Driver.Rules, 2)
+
+	results := doc.Runs[0].Results
+	assert.Len(t, results, 2)
+	assert.Equal(t, "broken-link", results[0].RuleId)
+	assert.Equal(t, "http://example.com/", results[0].Locations[0].PhysicalLocation.ArtifactLocation.Uri)
+	assert.Empty(t, results[0].Suppressions)
+
+	// Baselined violations are suppressed
+	assert.Equal(t, "external", results[1].Suppressions[0].Kind)
+}
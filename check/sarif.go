@@ -0,0 +1,116 @@
+package check
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// sarifSchema of SARIF 2.1.0, as read by GitHub code scanning
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifLog struct {
+	Schema  string      `json:"$schema"`
+	Version string      `json:"version"`
+	Runs    []*sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool      `json:"tool"`
+	Results []*sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string       `json:"name"`
+	InformationUri string       `json:"informationUri"`
+	Rules          []*sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId       string              `json:"ruleId"`
+	Level        string              `json:"level"`
+	Message      sarifMessage        `json:"message"`
+	Locations    []*sarifLocation    `json:"locations"`
+	Suppressions []*sarifSuppression `json:"suppressions,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	Uri string `json:"uri"`
+}
+
+type sarifSuppression struct {
+	Kind string `json:"kind"`
+}
+
+// WriteSARIF writes the result as a SARIF log, with a result for each
+// violation located at the page violating the rule. Baselined violations
+// are included, suppressed externally.
+func WriteSARIF(w io.Writer, res *Result) error {
+	run := &sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "kraken",
+				InformationUri: "https://github.com/mattheath/kraken",
+				Rules:          make([]*sarifRule, 0),
+			},
+		},
+		Results: make([]*sarifResult, 0),
+	}
+
+	for _, r := range res.Rules {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, &sarifRule{
+			Id:               r.Id,
+			ShortDescription: sarifMessage{Text: r.Description},
+		})
+	}
+
+	for _, v := range res.Violations {
+		result := &sarifResult{
+			RuleId:  v.Rule,
+			Level:   "error",
+			Message: sarifMessage{Text: v.Message},
+			Locations: []*sarifLocation{
+				&sarifLocation{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{Uri: v.Url},
+					},
+				},
+			},
+		}
+		if v.Baselined {
+			result.Suppressions = []*sarifSuppression{
+				&sarifSuppression{Kind: "external"},
+			}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(&sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs:    []*sarifRun{run},
+	})
+}
@@ -1,6 +1,7 @@
 package crawler
 
 import (
+	"errors"
 	"net/url"
 
 	log "github.com/cihub/seelog"
@@ -11,6 +12,7 @@ import (
 // Crawler interface provides methods to extract data from a crawler
 type Crawler interface {
 	AllPages() []*domain.Page
+	AllDiscovered() []*domain.Page
 	Target() *url.URL
 	TotalRequests() int
 }
@@ -21,6 +23,10 @@ type crawler struct {
 	Pages map[string]*domain.Page
 	Links map[string]*domain.Link
 
+	// Unfetched pages we discovered but didn't crawl successfully,
+	// which are tagged with why
+	Unfetched map[string]*domain.Page
+
 	// completed channel is an inbound queue of completed requests
 	// for processing by the main crawler goroutine
 	completed chan *Result
@@ -40,6 +46,35 @@ type crawler struct {
 
 	// target stores our original target for comparisons
 	target *url.URL
+
+	// depth is the maximum depth we crawl to from our target
+	depth int
+
+	// seeds are crawled alongside our target, eg. pages listed in sitemaps
+	seeds []*seed
+
+	// sources tracks where each page was first discovered from
+	sources map[string]string
+
+	// requested tracks the most depth remaining each URL we have fetched,
+	// or are fetching, was requested with, so each is only fetched once
+	// and recorded at the shortest depth it was found at
+	requested map[string]int
+
+	// order pages are returned in, if any
+	order string
+
+	// handlers are called with each page as it completes
+	handlers []PageHandler
+}
+
+// PageHandler is called with each page as soon as it has been crawled
+type PageHandler func(page *domain.Page)
+
+// seed is a page to crawl in addition to those linked from our target
+type seed struct {
+	url    *url.URL
+	source string
 }
 
 // NewCrawler initialises and returns a new Crawler
@@ -53,14 +88,19 @@ func NewCrawler() *crawler {
 		errored:   make(chan *Result),
 
 		// Initialise results containers
-		Pages: make(map[string]*domain.Page),
-		Links: make(map[string]*domain.Link),
+		Pages:     make(map[string]*domain.Page),
+		Links:     make(map[string]*domain.Link),
+		Unfetched: make(map[string]*domain.Page),
+
+		sources:   make(map[string]string),
+		requested: make(map[string]int),
 	}
 
 	return c
 }
 
-// AllPages retrieves all of the pages the crawler has found
+// AllPages retrieves all of the pages the crawler has found, sorted
+// if an order has been set
 func (c *crawler) AllPages() []*domain.Page {
 	ret := make([]*domain.Page, len(c.Pages))
 
@@ -71,6 +111,43 @@ func (c *crawler) AllPages() []*domain.Page {
 		i++
 	}
 
+	domain.SortPages(ret, c.order)
+
+	return ret
+}
+
+// OnPage adds a handler which is called with each page as soon as it
+// has been crawled, eg. to stream results. Handlers are called in turn
+// from Work, so need no locking, but should not block for long.
+// Pages later found by a shorter path have their depth corrected, but
+// aren't handled again. Handlers must be added before calling Work.
+func (c *crawler) OnPage(h PageHandler) {
+	c.handlers = append(c.handlers, h)
+}
+
+// SetOrder sets the order pages are returned in, by URL or by depth
+// then URL, so crawls of the same site are output identically
+func (c *crawler) SetOrder(order string) error {
+	if !domain.ValidOrder(order) {
+		return domain.UnknownOrder
+	}
+
+	c.order = order
+	return nil
+}
+
+// AllDiscovered retrieves every page the crawler has discovered, including
+// those which errored or were skipped, sorted if an order has been set
+func (c *crawler) AllDiscovered() []*domain.Page {
+	ret := c.AllPages()
+	for u, p := range c.Unfetched {
+		if _, ok := c.Pages[u]; !ok {
+			ret = append(ret, p)
+		}
+	}
+
+	domain.SortPages(ret, c.order)
+
 	return ret
 }
 
@@ -84,6 +161,15 @@ func (c *crawler) TotalRequests() int {
 	return c.totalRequests
 }
 
+// Seed adds a page to be crawled alongside our target, tagged with the
+// source it was discovered from. Seeds must be added before calling Work.
+func (c *crawler) Seed(u *url.URL, source string) {
+	c.seeds = append(c.seeds, &seed{
+		url:    u,
+		source: source,
+	})
+}
+
 // Result represents the result of a crawl request
 type Result struct {
 	Url   *url.URL
@@ -100,11 +186,23 @@ func (c *crawler) Work(target *url.URL, depth int, fetcher Fetcher) {
 
 	// Store our target to a URL
 	c.target = target
+	c.depth = depth
 
 	// Get our first page & track this
-	go c.crawl(c.target, depth, fetcher)
-	c.requestsInFlight++
-	c.totalRequests++
+	c.discovered(c.target, domain.SourceTarget)
+	c.request(c.target, depth, fetcher)
+
+	// Along with any seeds on our target domain, at the same depth
+	for _, s := range c.seeds {
+		if s.url.Host != c.target.Host {
+			log.Debugf("Skipping seed %s as not on target domain", s.url.String())
+			c.unfetched(s.url, s.source, 0, domain.OutcomeOutOfScope, nil)
+			continue
+		}
+
+		c.discovered(s.url, s.source)
+		c.request(s.url, depth, fetcher)
+	}
 
 	// Event loop
 	for {
@@ -112,37 +210,42 @@ func (c *crawler) Work(target *url.URL, depth int, fetcher Fetcher) {
 		case r := <-c.skipped:
 			log.Debugf("Page skipped for %s", r.Url)
 			c.totalRequests--
+			c.unfetched(r.Url, c.sources[r.Url.String()], c.depth-r.Depth, domain.OutcomeSkipped, nil)
 		case r := <-c.errored:
 			log.Debugf("Page errored for %s: %v", r.Url, r.Error)
+			outcome := domain.OutcomeError
+			switch r.Error {
+			case RobotsDisallowed:
+				outcome = domain.OutcomeBlocked
+			case LogoutSkipped:
+				outcome = domain.OutcomeSkipped
+			}
+			c.unfetched(r.Url, c.sources[r.Url.String()], c.depth-c.requested[r.Url.String()], outcome, r.Error)
+
+			// Fetchers may wrap the error, eg. in a url.Error
+			if p, ok := c.Unfetched[r.Url.String()]; ok && errors.Is(r.Error, RedirectLoop) {
+				p.RedirectLoop = true
+			}
 		case r := <-c.completed:
 			log.Debugf("Page complete for %s", r.Url)
 			if r.Page == nil {
 				break
 			}
 
-			// Process each link
-			for _, l := range r.Page.Links {
-
-				// Skip page if not on our target domain
-				if l.Target.Host != c.target.Host {
-					// log.Debugf("Skipping %s as not on target domain", source.String())
-					continue
-				}
-
-				// Check if we've already hit this page
-				if _, exists := c.Pages[l.Target.String()]; exists {
-					// log.Debugf("Skipping %s as already processed", l.Target.String())
-					continue
-				}
-
-				log.Debugf("Triggering crawl of %s from %s", l.Target.String(), r.Url.String())
-				go c.crawl(l.Target, r.Depth-1, fetcher)
-				c.requestsInFlight++
-				c.totalRequests++
-			}
-			log.Debugf("Fired %v new requests, %v currently in flight", len(r.Page.Links), c.requestsInFlight)
-
+			// Record the page at the shortest depth it was requested
+			// with, which may be nearer than when it was fetched
+			depth := c.requested[r.Url.String()]
+			r.Page.Source = c.sources[r.Url.String()]
+			r.Page.Depth = c.depth - depth
+			r.Page.Outcome = domain.OutcomeOk
 			c.Pages[r.Url.String()] = r.Page
+			delete(c.Unfetched, r.Url.String())
+
+			c.follow(r.Page, depth, fetcher)
+
+			for _, h := range c.handlers {
+				h(r.Page)
+			}
 
 		}
 
@@ -155,6 +258,98 @@ func (c *crawler) Work(target *url.URL, depth int, fetcher Fetcher) {
 	}
 }
 
+// unfetched records a page we discovered but didn't crawl successfully.
+// As pages may be requested more than once, being skipped is replaced
+// by any other outcome, which tells us more about the page, and pages
+// are recorded at the shortest depth they were found at.
+func (c *crawler) unfetched(u *url.URL, source string, depth int, outcome string, err error) {
+	if _, exists := c.Pages[u.String()]; exists {
+		return
+	}
+	if p, exists := c.Unfetched[u.String()]; exists && (p.Outcome != domain.OutcomeSkipped || outcome == domain.OutcomeSkipped) {
+		if depth < p.Depth {
+			p.Depth = depth
+		}
+		return
+	}
+
+	p := &domain.Page{
+		Url:     u,
+		Source:  source,
+		Depth:   depth,
+		Outcome: outcome,
+	}
+	if err != nil {
+		p.Error = err.Error()
+	}
+	c.Unfetched[u.String()] = p
+}
+
+// discovered records the source a page was found from, unless
+// we have already found it elsewhere
+func (c *crawler) discovered(u *url.URL, source string) {
+	if _, exists := c.sources[u.String()]; !exists {
+		c.sources[u.String()] = source
+	}
+}
+
+// follow the links on a crawled page, which has the specified depth
+// remaining to crawl to
+func (c *crawler) follow(page *domain.Page, depth int, fetcher Fetcher) {
+	fired := make(map[string]bool)
+	for _, l := range page.Links {
+
+		// Links of different types may share a target
+		if fired[l.Target.String()] {
+			continue
+		}
+		fired[l.Target.String()] = true
+
+		// Skip page if not on our target domain
+		if l.Target.Host != c.target.Host {
+			c.unfetched(l.Target, domain.SourceLink, c.depth-depth+1, domain.OutcomeOutOfScope, nil)
+			continue
+		}
+
+		log.Debugf("Triggering crawl of %s from %s", l.Target.String(), page.Url.String())
+		c.discovered(l.Target, domain.SourceLink)
+		c.request(l.Target, depth-1, fetcher)
+	}
+	log.Debugf("Followed %v links, %v requests currently in flight", len(fired), c.requestsInFlight)
+}
+
+// request crawls a URL to the specified depth, unless it has already
+// been requested. Requests at our maximum depth are skipped without
+// being fetched, so the URL may still be requested from nearer by.
+//
+// Pages are crawled in parallel, so may be requested by a longer path
+// first. If so, they're recorded at the shorter depth instead, and the
+// links on pages already crawled are followed again from there.
+func (c *crawler) request(u *url.URL, depth int, fetcher Fetcher) {
+	if prev, ok := c.requested[u.String()]; ok {
+		if depth <= prev {
+			// log.Debugf("Skipping %s as already requested", u.String())
+			return
+		}
+
+		c.requested[u.String()] = depth
+		if p, exists := c.Pages[u.String()]; exists {
+			p.Depth = c.depth - depth
+			c.follow(p, depth, fetcher)
+		} else if p, exists := c.Unfetched[u.String()]; exists {
+			p.Depth = c.depth - depth
+		}
+		return
+	}
+	if depth > 0 {
+		c.requested[u.String()] = depth
+	}
+
+	go c.crawl(u, depth, fetcher)
+	c.requestsInFlight++
+	c.totalRequests++
+}
+
 // crawl uses fetcher to recursively crawl
 // pages starting with url, to a maximum of depth.
 func (c *crawler) crawl(source *url.URL, depth int, fetcher Fetcher) {
@@ -173,29 +368,23 @@ func (c *crawler) crawl(source *url.URL, depth int, fetcher Fetcher) {
 	}
 
 	// Crawl the page, using our fetcher
-	urls, assets, err := fetcher.Fetch(source)
+	page, err := fetcher.Fetch(source)
 	if err != nil {
 		res.Error = err
 		c.errored <- res
 		return
 	}
 
-	log.Infof("%v URLs found at %s", len(urls), source.String())
+	log.Infof("%v URLs found at %s", len(page.Links), source.String())
 
-	links := make([]*domain.Link, 0)
-	for _, u := range urls {
-		links = append(links, &domain.Link{
-			Source: source,
-			Target: u,
-		})
+	// Pages are stored under the URL we requested, even if redirected
+	page.Url = source
+	for _, l := range page.Links {
+		l.Source = source
 	}
 
 	// Store this page and links into the result
-	res.Page = &domain.Page{
-		Url:    source,
-		Links:  links,
-		Assets: assets,
-	}
+	res.Page = page
 
 	// 	// Mark this page as complete
 	c.completed <- res
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"net/url"
 	"sort"
+	"sync"
 	"testing"
+	"time"
 
 	// log "github.com/cihub/seelog"
 	"github.com/davegardnerisme/deephash"
@@ -23,13 +25,34 @@ type fakeResult struct {
 	assets []string
 }
 
-func (f fakeFetcher) Fetch(target *url.URL) ([]*url.URL, []*url.URL, error) {
+func (f fakeFetcher) Fetch(target *url.URL) (*domain.Page, error) {
 	if res, ok := f[target.String()]; ok {
 		furls, _ := stringsToUrls(res.urls)
 		fassets, _ := stringsToUrls(res.assets)
-		return furls, fassets, nil
+
+		links := make([]*domain.Link, len(furls))
+		for i, u := range furls {
+			links[i] = &domain.Link{
+				Target: u,
+				Type:   domain.LinkAnchor,
+			}
+		}
+
+		assets := make([]*domain.Asset, len(fassets))
+		for i, u := range fassets {
+			assets[i] = &domain.Asset{
+				Url:  u,
+				Type: domain.AssetImage,
+			}
+		}
+
+		return &domain.Page{
+			Url:    target,
+			Links:  links,
+			Assets: assets,
+		}, nil
 	}
-	return nil, nil, errors.New("not found: " + target.String())
+	return nil, errors.New("not found: " + target.String())
 }
 
 // fetcher is a populated fakeFetcher.
@@ -164,6 +187,222 @@ func TestCrawlSkipAtMaxDepth(t *testing.T) {
 
 }
 
+func TestWorkWithSeeds(t *testing.T) {
+
+	// Only reachable from a seed, as nothing links to it
+	seeded := fakeFetcher{
+		"http://golang.org/pkg/unlinked/": &fakeResult{
+			"Unlinked",
+			[]string{
+				"http://golang.org/pkg/",
+			},
+			[]string{},
+		},
+	}
+	for k, v := range fetcher {
+		seeded[k] = v
+	}
+
+	c := NewCrawler()
+	c.Seed(strToUrl("http://golang.org/pkg/unlinked/"), domain.SourceSitemap)
+	c.Seed(strToUrl("http://elsewhere.com/"), domain.SourceSitemap)
+	c.Seed(strToUrl("http://golang.org/"), domain.SourceSitemap)
+	c.Work(strToUrl("http://golang.org/"), 2, seeded)
+
+	assert.Contains(t, c.Pages, "http://golang.org/pkg/unlinked/")
+	assert.NotContains(t, c.Pages, "http://elsewhere.com/")
+
+	// Pages are tagged with where they were first discovered
+	assert.Equal(t, domain.SourceSitemap, c.Pages["http://golang.org/pkg/unlinked/"].Source)
+	assert.Equal(t, domain.SourceTarget, c.Pages["http://golang.org/"].Source)
+	assert.Equal(t, domain.SourceLink, c.Pages["http://golang.org/pkg/"].Source)
+
+	// Depth is counted from our target, or the seed a page was found from
+	assert.Equal(t, 0, c.Pages["http://golang.org/"].Depth)
+	assert.Equal(t, 1, c.Pages["http://golang.org/pkg/"].Depth)
+	assert.Equal(t, 0, c.Pages["http://golang.org/pkg/unlinked/"].Depth)
+}
+
+// fetcherFunc adapts a function to a Fetcher
+type fetcherFunc func(target *url.URL) (*domain.Page, error)
+
+func (f fetcherFunc) Fetch(target *url.URL) (*domain.Page, error) {
+	return f(target)
+}
+
+func TestWorkRecordsUnfetched(t *testing.T) {
+	blocking := fetcherFunc(func(target *url.URL) (*domain.Page, error) {
+		if target.Path == "/cmd/" {
+			return nil, RobotsDisallowed
+		}
+		return fetcher.Fetch(target)
+	})
+
+	c := NewCrawler()
+	c.Seed(strToUrl("http://golang.org/missing"), domain.SourceSitemap)
+	c.Seed(strToUrl("http://elsewhere.com/"), domain.SourceSitemap)
+	c.Work(strToUrl("http://golang.org/"), 2, blocking)
+
+	outcomes := make(map[string]string)
+	for _, p := range c.AllDiscovered() {
+		outcomes[p.Url.String()] = p.Outcome
+	}
+
+	assert.Equal(t, map[string]string{
+		"http://golang.org/":         domain.OutcomeOk,
+		"http://golang.org/pkg/":     domain.OutcomeOk,
+		"http://golang.org/cmd/":     domain.OutcomeBlocked,
+		"http://golang.org/pkg/fmt/": domain.OutcomeSkipped,
+		"http://golang.org/pkg/os/":  domain.OutcomeSkipped,
+		"http://golang.org/missing":  domain.OutcomeError,
+		"http://elsewhere.com/":      domain.OutcomeOutOfScope,
+	}, outcomes)
+
+	// Unfetched pages keep why they failed, along with where they were found
+	missing := c.Unfetched["http://golang.org/missing"]
+	assert.Equal(t, "not found: http://golang.org/missing", missing.Error)
+	assert.Equal(t, domain.SourceSitemap, missing.Source)
+	assert.Equal(t, 2, c.Unfetched["http://golang.org/pkg/fmt/"].Depth)
+
+	// Only pages which were fetched are included in AllPages
+	assert.Len(t, c.AllPages(), 2)
+}
+
+func TestWorkRecordsRedirectLoops(t *testing.T) {
+	looping := fetcherFunc(func(target *url.URL) (*domain.Page, error) {
+		if target.Path == "/cmd/" {
+			return nil, &url.Error{Op: "Get", URL: target.String(), Err: RedirectLoop}
+		}
+		return fetcher.Fetch(target)
+	})
+
+	c := NewCrawler()
+	c.Work(strToUrl("http://golang.org/"), 2, looping)
+
+	// Loops are recognised however the error was wrapped
+	assert.True(t, c.Unfetched["http://golang.org/cmd/"].RedirectLoop)
+	assert.Equal(t, domain.OutcomeError, c.Unfetched["http://golang.org/cmd/"].Outcome)
+	assert.False(t, c.Unfetched["http://golang.org/pkg/fmt/"].RedirectLoop)
+}
+
+func TestWorkSkipsLogout(t *testing.T) {
+	loggedIn := fetcherFunc(func(target *url.URL) (*domain.Page, error) {
+		switch target.Path {
+		case "/":
+			return &domain.Page{
+				Url:   target,
+				Links: []*domain.Link{{Target: strToUrl("http://golang.org/logout"), Type: domain.LinkAnchor}},
+			}, nil
+		case "/logout":
+			return nil, LogoutSkipped
+		}
+		return nil, errors.New("not found: " + target.String())
+	})
+
+	c := NewCrawler()
+	c.Work(strToUrl("http://golang.org/"), 2, loggedIn)
+
+	// Logout links aren't broken, we just chose not to follow them
+	logout := c.Unfetched["http://golang.org/logout"]
+	assert.Equal(t, domain.OutcomeSkipped, logout.Outcome)
+	assert.Equal(t, LogoutSkipped.Error(), logout.Error)
+}
+
+func TestOnPage(t *testing.T) {
+	streamed := make([]string, 0)
+
+	c := NewCrawler()
+	c.OnPage(func(p *domain.Page) {
+		// Pages are complete when handled
+		assert.Equal(t, p, c.Pages[p.Url.String()])
+		streamed = append(streamed, p.Url.String())
+	})
+	c.Work(strToUrl("http://golang.org/"), 3, fetcher)
+
+	assert.Equal(t, "http://golang.org/", streamed[0])
+	assert.Len(t, streamed, len(c.Pages))
+}
+
+func TestWorkFetchesOnce(t *testing.T) {
+	// A diamond, where /d/ is linked from both /b/ and /c/
+	diamond := fakeFetcher{
+		"http://golang.org/":   &fakeResult{"A", []string{"http://golang.org/b/", "http://golang.org/c/"}, []string{}},
+		"http://golang.org/b/": &fakeResult{"B", []string{"http://golang.org/d/"}, []string{}},
+		"http://golang.org/c/": &fakeResult{"C", []string{"http://golang.org/d/"}, []string{}},
+		"http://golang.org/d/": &fakeResult{"D", []string{"http://golang.org/"}, []string{}},
+	}
+
+	// Slow to fetch /d/, so both parents complete while it's in flight
+	var mtx sync.Mutex
+	fetches := make(map[string]int)
+	slow := fetcherFunc(func(target *url.URL) (*domain.Page, error) {
+		mtx.Lock()
+		fetches[target.String()]++
+		mtx.Unlock()
+		if target.Path == "/d/" {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return diamond.Fetch(target)
+	})
+
+	streamed := make(map[string]int)
+	c := NewCrawler()
+	c.OnPage(func(p *domain.Page) {
+		streamed[p.Url.String()]++
+	})
+	c.Work(strToUrl("http://golang.org/"), 3, slow)
+
+	for u := range diamond {
+		assert.Equal(t, 1, fetches[u], u)
+		assert.Equal(t, 1, streamed[u], u)
+	}
+	assert.Equal(t, 4, c.TotalRequests())
+}
+
+func TestWorkRecordsShortestDepth(t *testing.T) {
+	// /page/ is one link from /slow/, but three from / by way of /x/ and /y/
+	site := fakeFetcher{
+		"http://golang.org/":       &fakeResult{"Home", []string{"http://golang.org/slow/", "http://golang.org/x/"}, []string{}},
+		"http://golang.org/slow/":  &fakeResult{"Slow", []string{"http://golang.org/page/"}, []string{}},
+		"http://golang.org/x/":     &fakeResult{"X", []string{"http://golang.org/y/"}, []string{}},
+		"http://golang.org/y/":     &fakeResult{"Y", []string{"http://golang.org/page/"}, []string{}},
+		"http://golang.org/page/":  &fakeResult{"Page", []string{"http://golang.org/child/"}, []string{}},
+		"http://golang.org/child/": &fakeResult{"Child", []string{}, []string{}},
+	}
+
+	// So /page/ is crawled by the longer path first
+	slow := fetcherFunc(func(target *url.URL) (*domain.Page, error) {
+		if target.Path == "/slow/" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		return site.Fetch(target)
+	})
+
+	c := NewCrawler()
+	c.Work(strToUrl("http://golang.org/"), 4, slow)
+
+	assert.Equal(t, 2, c.Pages["http://golang.org/page/"].Depth)
+
+	// Which is near enough for its links to be followed after all
+	assert.Contains(t, c.Pages, "http://golang.org/child/")
+	assert.Equal(t, 3, c.Pages["http://golang.org/child/"].Depth)
+}
+
+func TestAllPagesOrder(t *testing.T) {
+	c := NewCrawler()
+	assert.Equal(t, domain.UnknownOrder, c.SetOrder("random"))
+	assert.Nil(t, c.SetOrder(domain.OrderUrl))
+	c.Work(strToUrl("http://golang.org/"), 3, fetcher)
+
+	urls := make([]string, 0)
+	for _, p := range c.AllPages() {
+		urls = append(urls, p.Url.String())
+	}
+
+	assert.True(t, len(urls) > 1)
+	assert.True(t, sort.StringsAreSorted(urls))
+}
+
 // newMockCrawler returns a crawler with buffered channels
 // suitable for single threaded use
 func newMockCrawler() *crawler {
@@ -1,11 +1,28 @@
 package crawler
 
 import (
+	"errors"
 	"net/url"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+var (
+	// RobotsDisallowed is returned by fetchers which won't fetch
+	// pages disallowed by robots.txt
+	RobotsDisallowed = errors.New("Disallowed by robots.txt")
+
+	// RedirectLoop is returned by fetchers when a page redirects
+	// back to a URL already visited on the way to it
+	RedirectLoop = errors.New("Redirect loop")
+
+	// LogoutSkipped is returned by fetchers which won't follow
+	// links which would end an authenticated session
+	LogoutSkipped = errors.New("Not following logout link as it would end our session")
 )
 
 type Fetcher interface {
-	// Fetch returns a slice of URLs found on the target page
-	// along with a slice of assets.
-	Fetch(target *url.URL) (urls []*url.URL, assets []*url.URL, err error)
+	// Fetch returns the target page, with the links
+	// and assets found on it.
+	Fetch(target *url.URL) (*domain.Page, error)
 }
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"net/http"
+)
+
+// Redacted replaces the values of headers carrying credentials
+const Redacted = "[redacted]"
+
+// credentialHeaders carry credentials or session cookies, which
+// shouldn't be written anywhere they may be shared
+var credentialHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// RedactHeader returns a copy of a header with the values of any
+// headers carrying credentials redacted
+func RedactHeader(header http.Header) http.Header {
+	ret := make(http.Header, len(header))
+	for name, values := range header {
+		ret[name] = values
+	}
+
+	for _, name := range credentialHeaders {
+		if values, ok := ret[name]; ok {
+			redacted := make([]string, len(values))
+			for i := range redacted {
+				redacted[i] = Redacted
+			}
+			ret[name] = redacted
+		}
+	}
+
+	return ret
+}
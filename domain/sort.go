@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"errors"
+	"sort"
+)
+
+// Orders pages can be sorted in
+const (
+	OrderUrl   = "url"
+	OrderDepth = "depth"
+)
+
+var (
+	UnknownOrder = errors.New("Unknown order, must be url or depth")
+)
+
+// ValidOrder determines if pages can be sorted in the specified order.
+// No order leaves pages as they are.
+func ValidOrder(order string) bool {
+	switch order {
+	case "", OrderUrl, OrderDepth:
+		return true
+	}
+
+	return false
+}
+
+// SortPages sorts pages by URL, or by depth then URL, along with the
+// links, assets and media within each page, so the same site always
+// produces the same output
+func SortPages(pages []*Page, order string) error {
+	switch order {
+	case "":
+		return nil
+	case OrderUrl:
+		sort.SliceStable(pages, func(i, j int) bool {
+			return pages[i].Url.String() < pages[j].Url.String()
+		})
+	case OrderDepth:
+		sort.SliceStable(pages, func(i, j int) bool {
+			if pages[i].Depth != pages[j].Depth {
+				return pages[i].Depth < pages[j].Depth
+			}
+			return pages[i].Url.String() < pages[j].Url.String()
+		})
+	default:
+		return UnknownOrder
+	}
+
+	for _, p := range pages {
+		p.sortContents()
+	}
+
+	return nil
+}
+
+// sortContents sorts everything found on a page by URL
+func (p *Page) sortContents() {
+	sort.SliceStable(p.Links, func(i, j int) bool {
+		a, b := p.Links[i], p.Links[j]
+		if a.Target.String() != b.Target.String() {
+			return a.Target.String() < b.Target.String()
+		}
+		return a.Type < b.Type
+	})
+	sort.SliceStable(p.Assets, func(i, j int) bool {
+		return p.Assets[i].Url.String() < p.Assets[j].Url.String()
+	})
+	sort.SliceStable(p.Images, func(i, j int) bool {
+		return p.Images[i].Url.String() < p.Images[j].Url.String()
+	})
+	sort.SliceStable(p.Videos, func(i, j int) bool {
+		return p.Videos[i].Url.String() < p.Videos[j].Url.String()
+	})
+	sort.SliceStable(p.Alternates, func(i, j int) bool {
+		return p.Alternates[i].Lang < p.Alternates[j].Lang
+	})
+}
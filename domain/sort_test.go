@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortPages(t *testing.T) {
+	pages := func() []*Page {
+		return []*Page{
+			&Page{Url: strToUrl("http://example.com/b"), Depth: 1},
+			&Page{Url: strToUrl("http://example.com/c"), Depth: 0},
+			&Page{
+				Url:   strToUrl("http://example.com/a"),
+				Depth: 2,
+				Links: []*Link{
+					&Link{Target: strToUrl("http://example.com/c"), Type: LinkNext},
+					&Link{Target: strToUrl("http://example.com/b"), Type: LinkAnchor},
+					&Link{Target: strToUrl("http://example.com/c"), Type: LinkAnchor},
+				},
+			},
+		}
+	}
+
+	testCases := map[string][]string{
+		"":         []string{"http://example.com/b", "http://example.com/c", "http://example.com/a"},
+		OrderUrl:   []string{"http://example.com/a", "http://example.com/b", "http://example.com/c"},
+		OrderDepth: []string{"http://example.com/c", "http://example.com/b", "http://example.com/a"},
+	}
+
+	for order, expected := range testCases {
+		ps := pages()
+		assert.Nil(t, SortPages(ps, order))
+
+		found := make([]string, len(ps))
+		for i, p := range ps {
+			found[i] = p.Url.String()
+		}
+		assert.Equal(t, expected, found, order)
+	}
+
+	// Links within pages are sorted by target, then type
+	ps := pages()
+	SortPages(ps, OrderUrl)
+
+	links := make([]string, len(ps[0].Links))
+	for i, l := range ps[0].Links {
+		links[i] = l.Type + " " + l.Target.String()
+	}
+	assert.Equal(t, []string{
+		LinkAnchor + " http://example.com/b",
+		LinkAnchor + " http://example.com/c",
+		LinkNext + " http://example.com/c",
+	}, links)
+
+	assert.Equal(t, UnknownOrder, SortPages(ps, "random"))
+}
+
+func strToUrl(s string) *url.URL {
+	u, _ := url.Parse(s)
+	return u
+}
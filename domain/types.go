@@ -1,16 +1,139 @@
 package domain
 
 import (
+	"net/http"
 	"net/url"
+	"time"
+)
+
+// Sources a page can be discovered from
+const (
+	SourceTarget  = "target"
+	SourceLink    = "link"
+	SourceSitemap = "sitemap"
+)
+
+// Outcomes of crawling a discovered URL
+const (
+	OutcomeOk         = "ok"
+	OutcomeError      = "error"
+	OutcomeSkipped    = "skipped"
+	OutcomeOutOfScope = "out_of_scope"
+	OutcomeBlocked    = "blocked"
 )
 
 type Page struct {
 	Url    *url.URL
 	Links  []*Link
-	Assets []*url.URL
+	Assets []*Asset
+
+	// Source the page was first discovered from, eg. a link or a sitemap
+	Source string
+
+	// Charset the page was decoded from, eg. utf-8 or shift_jis
+	Charset string
+
+	// Depth is the number of links followed to reach the page,
+	// from our target or a seed
+	Depth int
+
+	// LastModified time of the page, if known
+	LastModified time.Time
+
+	// Status code and Header the page was served with
+	Status int
+	Header http.Header
+
+	// RedirectStatus is the status the page first responded with,
+	// if it redirected, before the redirects were followed
+	RedirectStatus int
+
+	// Title of the page, from its title element
+	Title string
+
+	// Outcome of crawling the page, and the error if it failed.
+	// Outcomes describe the fetch, so pages which responded with an
+	// error status, eg. 404, are ok, and their Status tells us more.
+	// Pages which weren't fetched have no links, assets or metadata.
+	Outcome string
+	Error   string
+
+	// RedirectLoop is set on pages which failed as they redirect
+	// back to a URL already visited on the way
+	RedirectLoop bool
+
+	// Images and Videos embedded in the page, described
+	// for image and video sitemaps
+	Images []*Image
+	Videos []*Video
+
+	// Alternates are other language versions of the page,
+	// as annotated with hreflang
+	Alternates []*Alternate
+}
+
+// Alternate language version of a page
+type Alternate struct {
+	// Lang is the hreflang code, eg. en-GB or x-default
+	Lang string
+	Url  *url.URL
 }
 
+// Image embedded in a page
+type Image struct {
+	Url *url.URL
+
+	// Title and Caption, eg. from the image's title and alt text
+	Title   string
+	Caption string
+}
+
+// Video embedded in a page
+type Video struct {
+	Url *url.URL
+
+	// Thumbnail shown before the video plays, if known
+	Thumbnail *url.URL
+
+	Title       string
+	Description string
+}
+
+// Types of link, describing how a link was found on a page
+const (
+	LinkAnchor   = "anchor"
+	LinkArea     = "area"
+	LinkForm     = "form"
+	LinkRefresh  = "refresh"
+	LinkHeader   = "header"
+	LinkNext     = "next"
+	LinkPrev     = "prev"
+	LinkHreflang = "hreflang"
+)
+
 type Link struct {
 	Source *url.URL
 	Target *url.URL
+
+	// Type of link, eg. an anchor or a meta refresh
+	Type string
+
+	// Text of the link, eg. an anchor's text or an area's alt text
+	Text string
+}
+
+// Types of asset used by a page
+const (
+	AssetImage      = "image"
+	AssetScript     = "script"
+	AssetStylesheet = "stylesheet"
+	AssetIcon       = "icon"
+)
+
+// Asset is a static resource used by a page
+type Asset struct {
+	Url *url.URL
+
+	// Type of asset, eg. an image or a script
+	Type string
 }
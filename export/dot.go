@@ -0,0 +1,48 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dotEscaper escapes strings for use within quoted DOT identifiers
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ", "\r", " ")
+
+// WriteDOT writes the graph in Graphviz DOT format, with pages
+// identified by their URL
+func WriteDOT(w io.Writer, g *Graph) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprint(bw, "digraph {\n")
+
+	for _, n := range g.Nodes {
+		if !n.Crawled {
+			fmt.Fprintf(bw, "\t%s;\n", dotQuote(n.Url))
+			continue
+		}
+		fmt.Fprintf(bw, "\t%s [label=%s, depth=%d, status=%d];\n", dotQuote(n.Url), dotQuote(nodeLabel(n)), n.Depth, n.Status)
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, "\t%s -> %s [type=%s];\n", dotQuote(e.Source.Url), dotQuote(e.Target.Url), dotQuote(e.Type))
+	}
+
+	fmt.Fprint(bw, "}\n")
+
+	return bw.Flush()
+}
+
+// nodeLabel is the title of a page, or its URL if it has none
+func nodeLabel(n *Node) string {
+	if n.Title != "" {
+		return n.Title
+	}
+
+	return n.Url
+}
+
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+type gexf struct {
+	XMLName xml.Name  `xml:"gexf"`
+	Xmlns   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfGraph struct {
+	Mode            string            `xml:"mode,attr"`
+	DefaultEdgeType string            `xml:"defaultedgetype,attr"`
+	Attributes      []*gexfAttributes `xml:"attributes"`
+	Nodes           []*gexfNode       `xml:"nodes>node"`
+	Edges           []*gexfEdge       `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string           `xml:"class,attr"`
+	Attributes []*gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	Id    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	Id     string          `xml:"id,attr"`
+	Label  string          `xml:"label,attr"`
+	Values []*gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	Id     string          `xml:"id,attr"`
+	Source string          `xml:"source,attr"`
+	Target string          `xml:"target,attr"`
+	Values []*gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// gexfAttributeClasses declare the attributes of our nodes and edges
+var gexfAttributeClasses = []*gexfAttributes{
+	&gexfAttributes{
+		Class: "node",
+		Attributes: []*gexfAttribute{
+			&gexfAttribute{Id: "title", Title: "title", Type: "string"},
+			&gexfAttribute{Id: "depth", Title: "depth", Type: "integer"},
+			&gexfAttribute{Id: "status", Title: "status", Type: "integer"},
+		},
+	},
+	&gexfAttributes{
+		Class: "edge",
+		Attributes: []*gexfAttribute{
+			&gexfAttribute{Id: "type", Title: "type", Type: "string"},
+		},
+	},
+}
+
+// WriteGEXF writes the graph as GEXF, Gephi's native format,
+// with each page labelled by its URL
+func WriteGEXF(w io.Writer, g *Graph) error {
+	doc := &gexf{
+		Xmlns:   "http://www.gexf.net/1.2draft",
+		Version: "1.2",
+		Graph: gexfGraph{
+			Mode:            "static",
+			DefaultEdgeType: "directed",
+			Attributes:      gexfAttributeClasses,
+		},
+	}
+
+	for _, n := range g.Nodes {
+		gn := &gexfNode{
+			Id:    n.Id,
+			Label: n.Url,
+		}
+		if n.Crawled {
+			gn.Values = []*gexfAttValue{
+				&gexfAttValue{For: "title", Value: n.Title},
+				&gexfAttValue{For: "depth", Value: strconv.Itoa(n.Depth)},
+				&gexfAttValue{For: "status", Value: strconv.Itoa(n.Status)},
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gn)
+	}
+
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, &gexfEdge{
+			Id:     e.Id,
+			Source: e.Source.Id,
+			Target: e.Target.Id,
+			Values: []*gexfAttValue{
+				&gexfAttValue{For: "type", Value: e.Type},
+			},
+		})
+	}
+
+	return WriteXML(w, doc)
+}
@@ -0,0 +1,97 @@
+package export
+
+import (
+	"fmt"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+// Graph is the link graph of a crawl, with a node for every page
+// crawled or linked to, and an edge for every link between them
+type Graph struct {
+	Nodes []*Node
+	Edges []*Edge
+
+	// nodes indexes Nodes by URL
+	nodes map[string]*Node
+}
+
+// Node is a single page. Pages which were linked to but not crawled,
+// such as those on other sites, have no depth, status or title.
+type Node struct {
+	Id      string
+	Url     string
+	Crawled bool
+	Depth   int
+	Status  int
+	Title   string
+}
+
+// Edge is a link from one page to another. Where a page links to
+// another in several ways, the first way found is used.
+type Edge struct {
+	Id     string
+	Source *Node
+	Target *Node
+	Type   string
+}
+
+// NewGraph builds the link graph of the specified pages
+func NewGraph(pages []*domain.Page) *Graph {
+	g := &Graph{
+		Nodes: make([]*Node, 0),
+		Edges: make([]*Edge, 0),
+		nodes: make(map[string]*Node),
+	}
+
+	// Crawled pages first, so their attributes are known
+	for _, p := range pages {
+		if p == nil || p.Url == nil {
+			continue
+		}
+		n := g.node(p.Url.String())
+		n.Crawled = true
+		n.Depth = p.Depth
+		n.Status = p.Status
+		n.Title = p.Title
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range pages {
+		if p == nil || p.Url == nil {
+			continue
+		}
+		for _, l := range p.Links {
+			key := p.Url.String() + " " + l.Target.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			g.Edges = append(g.Edges, &Edge{
+				Id:     fmt.Sprintf("e%d", len(g.Edges)),
+				Source: g.node(p.Url.String()),
+				Target: g.node(l.Target.String()),
+				Type:   l.Type,
+			})
+		}
+	}
+
+	return g
+}
+
+// node for the specified URL, added to the graph if not yet present
+func (g *Graph) node(u string) *Node {
+	if n, ok := g.nodes[u]; ok {
+		return n
+	}
+
+	n := &Node{
+		Id:  fmt.Sprintf("n%d", len(g.Nodes)),
+		Url: u,
+	}
+	g.Nodes = append(g.Nodes, n)
+	g.nodes[u] = n
+
+	return n
+}
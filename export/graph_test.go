@@ -0,0 +1,108 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+// testPages is a small site, with a home page linking to about
+// twice, and to another site
+func testPages() []*domain.Page {
+	home := strToUrl("http://example.com/")
+	about := strToUrl("http://example.com/about")
+
+	return []*domain.Page{
+		&domain.Page{
+			Url:    home,
+			Status: 200,
+			Title:  `The "Kraken"`,
+			Links: []*domain.Link{
+				&domain.Link{Source: home, Target: about, Type: domain.LinkAnchor},
+				&domain.Link{Source: home, Target: about, Type: domain.LinkNext},
+				&domain.Link{Source: home, Target: strToUrl("http://github.com/"), Type: domain.LinkAnchor},
+			},
+		},
+		&domain.Page{
+			Url:    about,
+			Status: 404,
+			Depth:  1,
+			Links: []*domain.Link{
+				&domain.Link{Source: about, Target: home, Type: domain.LinkAnchor},
+			},
+		},
+	}
+}
+
+func TestNewGraph(t *testing.T) {
+	g := NewGraph(testPages())
+
+	assert.Len(t, g.Nodes, 3)
+	assert.Equal(t, "http://example.com/about", g.Nodes[1].Url)
+	assert.Equal(t, 404, g.Nodes[1].Status)
+	assert.Equal(t, 1, g.Nodes[1].Depth)
+
+	// Pages which weren't crawled have no attributes
+	assert.Equal(t, "http://github.com/", g.Nodes[2].Url)
+	assert.False(t, g.Nodes[2].Crawled)
+
+	// Parallel links are merged, keeping the first type
+	assert.Len(t, g.Edges, 3)
+	assert.Equal(t, domain.LinkAnchor, g.Edges[0].Type)
+	assert.Equal(t, g.Nodes[0], g.Edges[2].Target)
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, WriteDOT(&buf, NewGraph(testPages())))
+
+	assert.Equal(t, `digraph {
+	"http://example.com/" [label="The \"Kraken\"", depth=0, status=200];
+	"http://example.com/about" [label="http://example.com/about", depth=1, status=404];
+	"http://github.com/";
+	"http://example.com/" -> "http://example.com/about" [type="anchor"];
+	"http://example.com/" -> "http://github.com/" [type="anchor"];
+	"http://example.com/about" -> "http://example.com/" [type="anchor"];
+}
+`, buf.String())
+}
+
+func TestWriteGraphML(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, WriteGraphML(&buf, NewGraph(testPages())))
+
+	doc := &graphML{}
+	assert.Nil(t, xml.Unmarshal(buf.Bytes(), doc))
+	assert.Len(t, doc.Graph.Nodes, 3)
+	assert.Len(t, doc.Graph.Edges, 3)
+	assert.Equal(t, []*graphMLData{
+		&graphMLData{Key: "url", Value: "http://example.com/"},
+		&graphMLData{Key: "title", Value: `The "Kraken"`},
+		&graphMLData{Key: "depth", Value: "0"},
+		&graphMLData{Key: "status", Value: "200"},
+	}, doc.Graph.Nodes[0].Data)
+	assert.Equal(t, "n1", doc.Graph.Edges[0].Target)
+}
+
+func TestWriteGEXF(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, WriteGEXF(&buf, NewGraph(testPages())))
+
+	doc := &gexf{}
+	assert.Nil(t, xml.Unmarshal(buf.Bytes(), doc))
+	assert.Len(t, doc.Graph.Nodes, 3)
+	assert.Len(t, doc.Graph.Edges, 3)
+	assert.Equal(t, "http://example.com/about", doc.Graph.Nodes[1].Label)
+	assert.Equal(t, &gexfAttValue{For: "status", Value: "404"}, doc.Graph.Nodes[1].Values[2])
+	assert.Empty(t, doc.Graph.Nodes[2].Values)
+}
+
+func strToUrl(s string) *url.URL {
+	u, _ := url.Parse(s)
+	return u
+}
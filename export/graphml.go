@@ -0,0 +1,112 @@
+package export
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+type graphML struct {
+	XMLName xml.Name      `xml:"graphml"`
+	Xmlns   string        `xml:"xmlns,attr"`
+	Keys    []*graphMLKey `xml:"key"`
+	Graph   graphMLGraph  `xml:"graph"`
+}
+
+type graphMLKey struct {
+	Id   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	Id          string         `xml:"id,attr"`
+	EdgeDefault string         `xml:"edgedefault,attr"`
+	Nodes       []*graphMLNode `xml:"node"`
+	Edges       []*graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	Id   string         `xml:"id,attr"`
+	Data []*graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Id     string         `xml:"id,attr"`
+	Source string         `xml:"source,attr"`
+	Target string         `xml:"target,attr"`
+	Data   []*graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// graphMLKeys declare the attributes of our nodes and edges
+var graphMLKeys = []*graphMLKey{
+	&graphMLKey{Id: "url", For: "node", Name: "url", Type: "string"},
+	&graphMLKey{Id: "title", For: "node", Name: "title", Type: "string"},
+	&graphMLKey{Id: "depth", For: "node", Name: "depth", Type: "int"},
+	&graphMLKey{Id: "status", For: "node", Name: "status", Type: "int"},
+	&graphMLKey{Id: "type", For: "edge", Name: "type", Type: "string"},
+}
+
+// WriteGraphML writes the graph as GraphML, as read by yEd and Gephi
+func WriteGraphML(w io.Writer, g *Graph) error {
+	doc := &graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys:  graphMLKeys,
+		Graph: graphMLGraph{
+			Id:          "G",
+			EdgeDefault: "directed",
+		},
+	}
+
+	for _, n := range g.Nodes {
+		gn := &graphMLNode{
+			Id: n.Id,
+			Data: []*graphMLData{
+				&graphMLData{Key: "url", Value: n.Url},
+			},
+		}
+		if n.Crawled {
+			gn.Data = append(gn.Data,
+				&graphMLData{Key: "title", Value: n.Title},
+				&graphMLData{Key: "depth", Value: strconv.Itoa(n.Depth)},
+				&graphMLData{Key: "status", Value: strconv.Itoa(n.Status)},
+			)
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gn)
+	}
+
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, &graphMLEdge{
+			Id:     e.Id,
+			Source: e.Source.Id,
+			Target: e.Target.Id,
+			Data: []*graphMLData{
+				&graphMLData{Key: "type", Value: e.Type},
+			},
+		})
+	}
+
+	return WriteXML(w, doc)
+}
+
+// WriteXML writes an indented XML document, with an XML header
+func WriteXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,177 @@
+package export
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+// SQLiteSchema is the schema of the database written by WriteSQLite.
+// Every URL discovered has a page, and links to pages which were
+// discovered have a target_id, so the link graph can be joined.
+const SQLiteSchema = `
+CREATE TABLE pages (
+	id            INTEGER PRIMARY KEY,
+	url           TEXT NOT NULL UNIQUE,
+	status        INTEGER,
+	depth         INTEGER NOT NULL,
+	title         TEXT,
+	source        TEXT,
+	charset       TEXT,
+	last_modified TEXT,
+	outcome       TEXT
+);
+
+CREATE TABLE links (
+	source_id  INTEGER NOT NULL REFERENCES pages (id),
+	target_url TEXT NOT NULL,
+	target_id  INTEGER REFERENCES pages (id),
+	type       TEXT NOT NULL,
+	text       TEXT
+);
+
+CREATE TABLE assets (
+	page_id INTEGER NOT NULL REFERENCES pages (id),
+	url     TEXT NOT NULL,
+	type    TEXT NOT NULL
+);
+
+CREATE TABLE errors (
+	page_id INTEGER NOT NULL REFERENCES pages (id),
+	error   TEXT NOT NULL
+);
+
+CREATE TABLE headers (
+	page_id INTEGER NOT NULL REFERENCES pages (id),
+	name    TEXT NOT NULL,
+	value   TEXT NOT NULL
+);
+
+CREATE INDEX links_source ON links (source_id);
+CREATE INDEX links_target ON links (target_id);
+CREATE INDEX assets_page ON assets (page_id);
+CREATE INDEX headers_page ON headers (page_id);
+`
+
+// WriteSQLite creates our schema in an empty SQLite database, and
+// writes pages along with their links, assets, errors and headers
+func WriteSQLite(db *sql.DB, pages []*domain.Page) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := writeSQLite(tx, pages); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func writeSQLite(tx *sql.Tx, pages []*domain.Page) error {
+	if _, err := tx.Exec(SQLiteSchema); err != nil {
+		return err
+	}
+
+	insertPage, err := tx.Prepare(`INSERT INTO pages (id, url, status, depth, title, source, charset, last_modified, outcome) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	insertLink, err := tx.Prepare(`INSERT INTO links (source_id, target_url, target_id, type, text) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	insertAsset, err := tx.Prepare(`INSERT INTO assets (page_id, url, type) VALUES (?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	insertError, err := tx.Prepare(`INSERT INTO errors (page_id, error) VALUES (?, ?)`)
+	if err != nil {
+		return err
+	}
+	insertHeader, err := tx.Prepare(`INSERT INTO headers (page_id, name, value) VALUES (?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+
+	// Pages first, so links can reference their targets
+	ids := make(map[string]int64)
+	for _, p := range pages {
+		if p == nil || p.Url == nil || ids[p.Url.String()] != 0 {
+			continue
+		}
+		id := int64(len(ids) + 1)
+		ids[p.Url.String()] = id
+
+		var lastModified sql.NullString
+		if !p.LastModified.IsZero() {
+			lastModified = sql.NullString{String: p.LastModified.UTC().Format(time.RFC3339), Valid: true}
+		}
+
+		_, err := insertPage.Exec(id, p.Url.String(), nullInt(p.Status), p.Depth, nullString(p.Title),
+			nullString(p.Source), nullString(p.Charset), lastModified, nullString(p.Outcome))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, p := range pages {
+		if p == nil || p.Url == nil {
+			continue
+		}
+		id := ids[p.Url.String()]
+
+		for _, l := range p.Links {
+			var target sql.NullInt64
+			if tid, ok := ids[l.Target.String()]; ok {
+				target = sql.NullInt64{Int64: tid, Valid: true}
+			}
+			if _, err := insertLink.Exec(id, l.Target.String(), target, l.Type, nullString(l.Text)); err != nil {
+				return err
+			}
+		}
+
+		for _, a := range p.Assets {
+			if _, err := insertAsset.Exec(id, a.Url.String(), a.Type); err != nil {
+				return err
+			}
+		}
+
+		if p.Error != "" {
+			if _, err := insertError.Exec(id, p.Error); err != nil {
+				return err
+			}
+		}
+
+		// Headers in a consistent order, as maps have none, without
+		// the values of any carrying credentials such as cookies
+		header := domain.RedactHeader(p.Header)
+		names := make([]string, 0, len(header))
+		for name := range header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, v := range header[name] {
+				if _, err := insertHeader.Exec(id, name, v); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// nullString stores empty strings as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullInt stores zero, eg. an unknown status, as NULL
+func nullInt(i int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(i), Valid: i != 0}
+}
@@ -0,0 +1,70 @@
+package export
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+func TestWriteSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// Each connection to an in-memory database has its own database
+	db.SetMaxOpenConns(1)
+
+	pages := testPages()
+	pages[0].Header = http.Header{
+		"Content-Type": []string{"text/html"},
+		"Set-Cookie":   []string{"session=abc", "tracking=xyz"},
+	}
+	pages[0].Assets = []*domain.Asset{
+		&domain.Asset{Url: strToUrl("http://example.com/logo.png"), Type: domain.AssetImage},
+	}
+	pages = append(pages, &domain.Page{
+		Url:     strToUrl("http://example.com/down"),
+		Depth:   1,
+		Outcome: domain.OutcomeError,
+		Error:   "connection refused",
+	})
+	assert.NoError(t, WriteSQLite(db, pages))
+
+	count := func(query string) int {
+		var n int
+		assert.NoError(t, db.QueryRow(query).Scan(&n))
+		return n
+	}
+	assert.Equal(t, 3, count(`SELECT COUNT(*) FROM pages`))
+	assert.Equal(t, 4, count(`SELECT COUNT(*) FROM links`))
+	assert.Equal(t, 1, count(`SELECT COUNT(*) FROM assets`))
+	assert.Equal(t, 1, count(`SELECT COUNT(*) FROM headers WHERE name = 'Content-Type' AND value = 'text/html'`))
+
+	// Cookies are redacted, so the database can be shared
+	assert.Equal(t, 2, count(`SELECT COUNT(*) FROM headers WHERE name = 'Set-Cookie' AND value = '[redacted]'`))
+	assert.Equal(t, 0, count(`SELECT COUNT(*) FROM headers WHERE value LIKE '%session%'`))
+
+	// Links to other sites have no target page
+	assert.Equal(t, 1, count(`SELECT COUNT(*) FROM links WHERE target_id IS NULL`))
+
+	// Inlinks can be counted by joining pages with their links
+	var url string
+	var inlinks int
+	err = db.QueryRow(`
+		SELECT p.url, COUNT(DISTINCT l.source_id) FROM pages p
+		JOIN links l ON l.target_id = p.id
+		WHERE p.depth >= 1 GROUP BY p.id`).Scan(&url, &inlinks)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com/about", url)
+	assert.Equal(t, 1, inlinks)
+
+	var msg string
+	err = db.QueryRow(`SELECT e.error FROM errors e JOIN pages p ON p.id = e.page_id WHERE p.outcome = 'error'`).Scan(&msg)
+	assert.NoError(t, err)
+	assert.Equal(t, "connection refused", msg)
+}
@@ -0,0 +1,154 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mattheath/kraken/analysis"
+	"github.com/mattheath/kraken/domain"
+)
+
+// Columns available in each flat table
+var (
+	PageColumns  = []string{"url", "status", "depth", "title", "inlinks", "outlinks", "pagerank"}
+	EdgeColumns  = []string{"source", "target", "type", "text"}
+	AssetColumns = []string{"url", "type", "pages"}
+)
+
+// Row of a flat table, keyed by column
+type Row map[string]string
+
+// PageRows has a row for each crawled page, counting the distinct
+// crawled pages it links to and is linked from, with its PageRank
+func PageRows(pages []*domain.Page) []Row {
+	g := NewGraph(pages)
+	metrics := analysis.LinkMetrics(pages)
+
+	rows := make([]Row, 0)
+	for _, n := range g.Nodes {
+		if !n.Crawled {
+			continue
+		}
+
+		m := metrics[n.Url]
+		if m == nil {
+			m = &analysis.PageMetrics{}
+		}
+		rows = append(rows, Row{
+			"url":      n.Url,
+			"status":   strconv.Itoa(n.Status),
+			"depth":    strconv.Itoa(n.Depth),
+			"title":    n.Title,
+			"inlinks":  strconv.Itoa(m.Inlinks),
+			"outlinks": strconv.Itoa(m.Outlinks),
+			"pagerank": strconv.FormatFloat(m.PageRank, 'f', 6, 64),
+		})
+	}
+
+	return rows
+}
+
+// EdgeRows has a row for every link found on every page
+func EdgeRows(pages []*domain.Page) []Row {
+	rows := make([]Row, 0)
+	for _, p := range pages {
+		if p == nil || p.Url == nil {
+			continue
+		}
+		for _, l := range p.Links {
+			rows = append(rows, Row{
+				"source": p.Url.String(),
+				"target": l.Target.String(),
+				"type":   l.Type,
+				"text":   l.Text,
+			})
+		}
+	}
+
+	return rows
+}
+
+// AssetRows has a row for each asset, listing the pages using it
+// separated by spaces
+func AssetRows(pages []*domain.Page) []Row {
+	rows := make([]Row, 0)
+	index := make(map[string]Row)
+
+	for _, p := range pages {
+		if p == nil || p.Url == nil {
+			continue
+		}
+		for _, a := range p.Assets {
+			if row, ok := index[a.Url.String()]; ok {
+				row["pages"] += " " + p.Url.String()
+				continue
+			}
+
+			row := Row{
+				"url":   a.Url.String(),
+				"type":  a.Type,
+				"pages": p.Url.String(),
+			}
+			index[a.Url.String()] = row
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+// SelectColumns parses a comma separated list of columns, which must
+// all be available. All available columns are selected if none are.
+func SelectColumns(available []string, selected string) ([]string, error) {
+	if strings.TrimSpace(selected) == "" {
+		return available, nil
+	}
+
+	ret := make([]string, 0)
+	for _, c := range strings.Split(selected, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if !contains(available, c) {
+			return nil, fmt.Errorf("Unknown column '%s', must be one of %s", c, strings.Join(available, ", "))
+		}
+		ret = append(ret, c)
+	}
+
+	return ret, nil
+}
+
+// WriteTable writes the selected columns of each row, after a header,
+// separated by the delimiter, eg. ',' for CSV or '\t' for TSV
+func WriteTable(w io.Writer, delimiter rune, columns []string, rows []Row) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = delimiter
+
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, c := range columns {
+			record[i] = row[c]
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,65 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+func TestWriteTables(t *testing.T) {
+	pages := testPages()
+	pages[0].Links[0].Text = "About, us"
+	pages[0].Assets = []*domain.Asset{
+		&domain.Asset{Url: strToUrl("http://example.com/logo.png"), Type: domain.AssetImage},
+	}
+	pages[1].Assets = pages[0].Assets
+
+	testCases := []struct {
+		columns   []string
+		rows      []Row
+		delimiter rune
+		expected  string
+	}{
+		{
+			PageColumns, PageRows(pages), ',',
+			"url,status,depth,title,inlinks,outlinks,pagerank\n" +
+				"http://example.com/,200,0,\"The \"\"Kraken\"\"\",1,1,0.500000\n" +
+				"http://example.com/about,404,1,,1,1,0.500000\n",
+		},
+		{
+			EdgeColumns, EdgeRows(pages), ',',
+			"source,target,type,text\n" +
+				"http://example.com/,http://example.com/about,anchor,\"About, us\"\n" +
+				"http://example.com/,http://example.com/about,next,\n" +
+				"http://example.com/,http://github.com/,anchor,\n" +
+				"http://example.com/about,http://example.com/,anchor,\n",
+		},
+		{
+			AssetColumns, AssetRows(pages), '\t',
+			"url\ttype\tpages\n" +
+				"http://example.com/logo.png\timage\thttp://example.com/ http://example.com/about\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		assert.Nil(t, WriteTable(&buf, tc.delimiter, tc.columns, tc.rows))
+		assert.Equal(t, tc.expected, buf.String())
+	}
+}
+
+func TestSelectColumns(t *testing.T) {
+	columns, err := SelectColumns(PageColumns, "")
+	assert.Nil(t, err)
+	assert.Equal(t, PageColumns, columns)
+
+	columns, err = SelectColumns(PageColumns, "URL, inlinks")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"url", "inlinks"}, columns)
+
+	_, err = SelectColumns(PageColumns, "url,anchor")
+	assert.NotNil(t, err)
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"strings"
 
@@ -9,53 +12,202 @@ import (
 	atom "code.google.com/p/go.net/html/atom"
 	"github.com/PuerkitoBio/goquery"
 	log "github.com/cihub/seelog"
+
+	"github.com/mattheath/kraken/crawler"
+	"github.com/mattheath/kraken/domain"
 )
 
 var (
-	InvalidNode                 = errors.New("Node is not an anchor")
+	InvalidNode                 = errors.New("Node is not an anchor or area")
 	InvalidNodeAttributeMissing = errors.New("Node does not contain the specified attribute")
 )
 
 type Fetcher interface {
-	// Fetch returns a slice of URLs found on the target page
-	// along with a slice of assets.
-	Fetch(target *url.URL) (urls []*url.URL, assets []*url.URL, err error)
+	// Fetch returns the target page, with the links
+	// and assets found on it.
+	Fetch(target *url.URL) (*domain.Page, error)
+}
+
+// Retriever is implemented by fetchers which can return the raw body
+// at a URL, such as a robots.txt file or sitemap
+type Retriever interface {
+	Retrieve(target *url.URL) (io.ReadCloser, error)
 }
 
-type HttpFetcher struct{}
+type HttpFetcher struct {
+	extractor
+
+	// Client used to make requests, http.DefaultClient if nil
+	Client *http.Client
+
+	// Auth used to log in and authenticate requests, if set
+	Auth Authenticator
+
+	// AuthHosts are the hosts credentials are sent to, such as our
+	// target's and the login form's, so they aren't sent to others
+	AuthHosts []string
+}
 
 // Fetch retrieves the page at the specified URL and extracts URLs
-func (h *HttpFetcher) Fetch(target *url.URL) ([]*url.URL, []*url.URL, error) {
+func (h *HttpFetcher) Fetch(target *url.URL) (*domain.Page, error) {
 
-	doc, err := goquery.NewDocument(target.String())
+	res, err := h.get(target)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
+	defer res.Body.Close()
 
-	urls, err := h.extractLinks(doc)
+	// Resolve links against where we ended up, after any redirects
+	page, err := h.read(res.Body, res.Request.URL, res.Header)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	page.Status = res.StatusCode
+	page.RedirectStatus = redirectStatus(res)
+
+	return page, nil
+}
+
+// redirectStatus returns the status of the first response in a chain of
+// redirects which led to a response, or 0 if it wasn't redirected to
+func redirectStatus(res *http.Response) int {
+	status := 0
+	for r := res.Request; r != nil && r.Response != nil; r = r.Response.Request {
+		status = r.Response.StatusCode
 	}
 
-	assets, err := h.extractAssets(doc)
+	return status
+}
+
+// Retrieve returns the body at the specified URL, which must respond OK
+func (h *HttpFetcher) Retrieve(target *url.URL) (io.ReadCloser, error) {
+
+	res, err := h.get(target)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("Retrieving %s failed with status %v", target, res.StatusCode)
+	}
+
+	return res.Body, nil
+}
+
+// checkRedirect stops following redirects which loop back to a URL
+// already visited, or after 10 redirects as http.Client does by default
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	for _, r := range via {
+		if r.URL.String() == req.URL.String() {
+			return crawler.RedirectLoop
+		}
+	}
+	if len(via) >= maxRedirects {
+		return TooManyRedirects
 	}
 
-	log.Debugf("URLs: %+v", urls)
+	return nil
+}
+
+// get makes an authenticated GET request for the specified URL
+func (h *HttpFetcher) get(target *url.URL) (*http.Response, error) {
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequest("GET", target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Authenticate, taking care not to end our own session
+	if h.Auth != nil && h.authenticates(target) {
+		if isLogoutUrl(target) {
+			return nil, crawler.LogoutSkipped
+		}
+		h.Auth.Apply(req)
+	}
+
+	return client.Do(req)
+}
+
+// authenticates determines if credentials should be sent to a URL
+func (h *HttpFetcher) authenticates(target *url.URL) bool {
+	for _, host := range h.AuthHosts {
+		if strings.EqualFold(host, target.Host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// extractor pulls links and assets out of parsed documents, and is
+// shared between fetchers which retrieve documents in different ways
+type extractor struct{}
+
+// extract links and assets from a document, along with
+// any links in the headers it was served with
+func (e *extractor) extract(doc *goquery.Document, header http.Header) (*domain.Page, error) {
+
+	links, err := e.extractLinks(doc)
+	if err != nil {
+		return nil, err
+	}
+	links = append(links, e.extractHeaderLinks(doc.Url, header)...)
+
+	// Follow alternate languages, so their annotations can be checked
+	alternates := e.extractAlternates(doc, header)
+	for _, a := range alternates {
+		links = append(links, &domain.Link{
+			Target: a.Url,
+			Type:   domain.LinkHreflang,
+		})
+	}
+	links = e.dedupeLinks(links)
+
+	assets, err := e.extractAssets(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Links: %+v", links)
 	log.Debugf("Assets: %+v", assets)
 
-	return urls, assets, nil
+	return &domain.Page{
+		Url:          doc.Url,
+		Links:        links,
+		Assets:       assets,
+		Header:       header,
+		LastModified: e.extractLastModified(doc, header),
+		Title:        e.extractTitle(doc),
+		Images:       e.extractImages(doc),
+		Videos:       e.extractVideos(doc),
+		Alternates:   alternates,
+	}, nil
 }
 
-// extractLinks from a document
-func (h *HttpFetcher) extractLinks(doc *goquery.Document) ([]*url.URL, error) {
+// extractLinks from a document, recording the type of element
+// each link was found in
+func (e *extractor) extractLinks(doc *goquery.Document) ([]*domain.Link, error) {
 
 	// Blank slice to hold the links on this page
-	urls := make([]*url.URL, 0)
+	links := make([]*domain.Link, 0)
+	add := func(href, linkType, text string) {
+		if uri := e.normaliseUrl(doc.Url, href); uri != nil {
+			links = append(links, &domain.Link{
+				Target: uri,
+				Type:   linkType,
+				Text:   text,
+			})
+		}
+	}
 
-	// Extract all 'a' elements from the document
-	sel := doc.Find("a")
+	// Extract all 'a' and 'area' elements from the document
+	sel := doc.Find("a, area")
 	if sel == nil {
 		// Assume zero links on failure
 		return nil, nil
@@ -65,105 +217,108 @@ func (h *HttpFetcher) extractLinks(doc *goquery.Document) ([]*url.URL, error) {
 	for _, n := range sel.Nodes {
 
 		// Validate the node is a link, and extract the target URL
-		href, err := h.extractValidHref(n)
+		href, err := e.extractValidHref(n)
 		if err != nil || href == "" {
 			continue
 		}
 
-		// Normalise the URL and add if valid
-		if uri := h.normaliseUrl(doc.Url, href); uri != nil {
-			urls = append(urls, uri)
+		// Areas are described by their alt text
+		text := nodeText(n)
+		if n.DataAtom == atom.Area {
+			text = collapseSpace(attr(n, "alt"))
 		}
-	}
-
-	return h.dedupeUrls(urls), nil
-}
 
-// extractAssets from a document
-// @todo break this up and add tests
-func (h *HttpFetcher) extractAssets(doc *goquery.Document) ([]*url.URL, error) {
+		// Pagination is more specific than the element it's found in
+		linkType := paginationType(attr(n, "rel"))
+		switch {
+		case linkType != "":
+		case n.DataAtom == atom.Area:
+			linkType = domain.LinkArea
+		default:
+			linkType = domain.LinkAnchor
+		}
 
-	var sel *goquery.Selection
-	assets := make([]*url.URL, 0)
+		add(href, linkType, text)
+	}
 
-	// First grab all the images
-	sel = doc.Find("img")
-	for _, n := range sel.Nodes {
-		if n == nil {
-			continue
+	// Pagination links in the head, eg. <link rel="next">
+	for _, n := range doc.Find("link").Nodes {
+		if linkType := paginationType(attr(n, "rel")); linkType != "" {
+			if href := attr(n, "href"); href != "" {
+				add(href, linkType, "")
+			}
 		}
-		for _, a := range n.Attr {
-			if a.Key == "src" && a.Val != "" {
-				if uri := h.normaliseUrl(doc.Url, a.Val); uri != nil {
-					assets = append(assets, uri)
-					break
-				}
+	}
 
-			}
+	// Forms which are submitted with GET
+	for _, n := range doc.Find("form").Nodes {
+		method := strings.ToLower(strings.TrimSpace(attr(n, "method")))
+		if action := attr(n, "action"); action != "" && (method == "" || method == "get") {
+			add(action, domain.LinkForm, "")
 		}
 	}
 
-	// Next scripts
-	sel = doc.Find("script")
-	for _, n := range sel.Nodes {
-		if n == nil {
+	// Meta refresh redirects
+	for _, n := range doc.Find("meta").Nodes {
+		if !strings.EqualFold(attr(n, "http-equiv"), "refresh") {
 			continue
 		}
-		for _, a := range n.Attr {
-			if a.Key == "src" && a.Val != "" {
-				if uri := h.normaliseUrl(doc.Url, a.Val); uri != nil {
-					assets = append(assets, uri)
-					break
-				}
-
-			}
+		if href := parseMetaRefresh(attr(n, "content")); href != "" {
+			add(href, domain.LinkRefresh, "")
 		}
 	}
 
-	// Links, eg styles, shortcut icons etc
-	sel = doc.Find("link")
-	for _, n := range sel.Nodes {
-		if n == nil {
-			continue
-		}
+	return links, nil
+}
 
-		// Pull out various fields
-		var rel, linktype string
-		var uri *url.URL
-		for _, a := range n.Attr {
-			switch a.Key {
-			case "rel":
-				rel = a.Val
-			case "type":
-				linktype = a.Val
-			case "href":
-				uri = h.normaliseUrl(doc.Url, a.Val)
-			}
-		}
+// extractAssets from a document, recording the type of each
+func (e *extractor) extractAssets(doc *goquery.Document) ([]*domain.Asset, error) {
 
-		// Continue if there is no link target
-		if uri == nil {
-			continue
+	assets := make([]*domain.Asset, 0)
+	add := func(href, assetType string) {
+		if href == "" {
+			return
 		}
+		if uri := e.normaliseUrl(doc.Url, href); uri != nil {
+			assets = append(assets, &domain.Asset{
+				Url:  uri,
+				Type: assetType,
+			})
+		}
+	}
+
+	// First grab all the images
+	for _, n := range doc.Find("img").Nodes {
+		add(attr(n, "src"), domain.AssetImage)
+	}
+
+	// Next scripts
+	for _, n := range doc.Find("script").Nodes {
+		add(attr(n, "src"), domain.AssetScript)
+	}
+
+	// Links, eg styles, shortcut icons etc
+	for _, n := range doc.Find("link").Nodes {
+		rel, linktype := attr(n, "rel"), attr(n, "type")
 
-		// Otherwise select specific combinations
+		// Select specific combinations
 		switch {
 		case rel == "stylesheet" && linktype == "text/css":
-			assets = append(assets, uri)
+			add(attr(n, "href"), domain.AssetStylesheet)
 		case rel == "shortcut icon":
-			assets = append(assets, uri)
+			add(attr(n, "href"), domain.AssetIcon)
 		}
 	}
 
-	return h.dedupeUrls(assets), nil
+	return e.dedupeAssets(assets), nil
 }
 
 // validateLink is an anchor with a href, and extract normalised url
-func (h *HttpFetcher) extractValidHref(n *html.Node) (string, error) {
+func (e *extractor) extractValidHref(n *html.Node) (string, error) {
 	var href string
 
-	// Confirm this node is an anchor element
-	if n == nil || n.Type != html.ElementNode || n.DataAtom != atom.A {
+	// Confirm this node is an anchor or area element
+	if n == nil || n.Type != html.ElementNode || (n.DataAtom != atom.A && n.DataAtom != atom.Area) {
 		return href, InvalidNode
 	}
 
@@ -178,7 +333,7 @@ func (h *HttpFetcher) extractValidHref(n *html.Node) (string, error) {
 }
 
 // normaliseUrl converts relative URLs to absolute URLs
-func (h *HttpFetcher) normaliseUrl(parent *url.URL, urlString string) *url.URL {
+func (e *extractor) normaliseUrl(parent *url.URL, urlString string) *url.URL {
 
 	// Strip off fragment
 	i := strings.LastIndex(urlString, "#")
@@ -199,17 +354,18 @@ func (h *HttpFetcher) normaliseUrl(parent *url.URL, urlString string) *url.URL {
 	return abs
 }
 
-func (h *HttpFetcher) dedupeUrls(original []*url.URL) []*url.URL {
+// dedupeAssets removes repeated references to the same asset
+func (e *extractor) dedupeAssets(original []*domain.Asset) []*domain.Asset {
 	seen := make(map[string]bool)
-	ret := make([]*url.URL, 0)
+	ret := make([]*domain.Asset, 0)
 
-	for _, u := range original {
-		if _, ok := seen[u.String()]; ok {
+	for _, a := range original {
+		if _, ok := seen[a.Url.String()]; ok {
 			continue
 		}
 
-		seen[u.String()] = true
-		ret = append(ret, u)
+		seen[a.Url.String()] = true
+		ret = append(ret, a)
 	}
 
 	return ret
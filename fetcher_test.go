@@ -1,12 +1,21 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	html "code.google.com/p/go.net/html"
 	atom "code.google.com/p/go.net/html/atom"
+	"github.com/PuerkitoBio/goquery"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/crawler"
+	"github.com/mattheath/kraken/domain"
 )
 
 func TestExtractValidHrefSuccess(t *testing.T) {
@@ -111,3 +120,91 @@ func TestNormaliseUrl(t *testing.T) {
 	}
 
 }
+
+func TestExtractAssets(t *testing.T) {
+	e := &extractor{}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html>
+<head>
+	<link rel="stylesheet" type="text/css" href="/style.css">
+	<link rel="shortcut icon" href="/favicon.ico">
+	<link rel="alternate" href="/feed.xml">
+	<script src="/app.js"></script>
+	<script>inline()</script>
+</head>
+<body>
+	<img src="/logo.png">
+	<img src="logo.png">
+</body>
+</html>`))
+	assert.Nil(t, err)
+	doc.Url, _ = url.Parse("http://example.com/")
+
+	assets, err := e.extractAssets(doc)
+	assert.Nil(t, err)
+
+	found := make([]string, len(assets))
+	for i, a := range assets {
+		found[i] = a.Type + " " + a.Url.String()
+	}
+
+	assert.Equal(t, []string{
+		domain.AssetImage + " http://example.com/logo.png",
+		domain.AssetScript + " http://example.com/app.js",
+		domain.AssetStylesheet + " http://example.com/style.css",
+		domain.AssetIcon + " http://example.com/favicon.ico",
+	}, found)
+}
+
+func TestHttpFetcherRedirectStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+		case "/moved":
+			http.Redirect(w, r, "/", http.StatusFound)
+		default:
+			fmt.Fprint(w, "Kraken!")
+		}
+	}))
+	defer server.Close()
+
+	fetcher := &HttpFetcher{}
+
+	// Pages which redirect keep the status they first responded with
+	page, err := fetcher.Fetch(strToUrl(server.URL + "/old"))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, page.Status)
+	assert.Equal(t, http.StatusMovedPermanently, page.RedirectStatus)
+
+	page, err = fetcher.Fetch(strToUrl(server.URL + "/"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, page.RedirectStatus)
+}
+
+func TestHttpFetcherRedirectLoop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/a", http.StatusFound)
+		default:
+			http.Redirect(w, r, r.URL.Path+"x", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	fetcher := &HttpFetcher{
+		Client: &http.Client{
+			CheckRedirect: checkRedirect,
+		},
+	}
+
+	_, err := fetcher.Fetch(strToUrl(server.URL + "/a"))
+	assert.True(t, errors.Is(err, crawler.RedirectLoop), "%v", err)
+
+	// Endless redirects which never loop still stop
+	_, err = fetcher.Fetch(strToUrl(server.URL + "/c"))
+	assert.True(t, errors.Is(err, TooManyRedirects), "%v", err)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+var (
+	UnknownHost = errors.New("Host is not served from the root directory")
+)
+
+// indexFile is served when a directory is requested
+const indexFile = "index.html"
+
+// FileFetcher serves pages from a local directory, such as the output
+// of a static site generator, instead of fetching them over the network
+type FileFetcher struct {
+	extractor
+
+	// Root directory which Host is mapped onto
+	Root string
+
+	// Host served from Root, URLs on any other host are not found
+	Host string
+}
+
+// Fetch resolves the specified URL to a file under Root and extracts URLs
+func (f *FileFetcher) Fetch(target *url.URL) (*domain.Page, error) {
+
+	if target.Host != f.Host {
+		return nil, UnknownHost
+	}
+
+	filename, base, err := f.resolve(target)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only HTML documents contain links, anything else is a leaf
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext != ".html" && ext != ".htm" {
+		log.Debugf("Not parsing %s as it is not an HTML file", filename)
+		return &domain.Page{
+			Url:    base,
+			Links:  []*domain.Link{},
+			Assets: []*domain.Asset{},
+			Status: http.StatusOK,
+		}, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Serve the modification time of the file, as a web server would
+	header := http.Header{}
+	if fi, err := file.Stat(); err == nil {
+		header.Set("Last-Modified", fi.ModTime().UTC().Format(http.TimeFormat))
+	}
+
+	// Relative links resolve against the URL the file would be served at
+	page, err := f.read(file, base, header)
+	if err != nil {
+		return nil, err
+	}
+	page.Status = http.StatusOK
+
+	return page, nil
+}
+
+// Retrieve opens the file the specified URL resolves to
+func (f *FileFetcher) Retrieve(target *url.URL) (io.ReadCloser, error) {
+
+	if target.Host != f.Host {
+		return nil, UnknownHost
+	}
+
+	filename, _, err := f.resolve(target)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(filename)
+}
+
+// resolve maps a URL onto a file under Root, following the same rules as
+// most static file servers: directories serve their index.html, and
+// extensionless paths fall back to a matching .html file. The URL relative
+// links on the page should be resolved against is also returned, as this
+// gains a trailing slash when a directory is requested without one.
+func (f *FileFetcher) resolve(target *url.URL) (string, *url.URL, error) {
+
+	// Clean the path so requests can't escape the root directory
+	p := path.Clean("/" + target.Path)
+	filename := filepath.Join(f.Root, filepath.FromSlash(p))
+	trailingSlash := strings.HasSuffix(target.Path, "/")
+
+	base := *target
+	base.Fragment = ""
+
+	fi, err := os.Stat(filename)
+	switch {
+	case err == nil && fi.IsDir():
+		if !trailingSlash {
+			base.Path = target.Path + "/"
+		}
+		filename = filepath.Join(filename, indexFile)
+	case err == nil && trailingSlash:
+		// Files can't be requested as if they were directories
+		return "", nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+	case err == nil:
+	case os.IsNotExist(err) && !trailingSlash && path.Ext(p) == "":
+		// Pretty URLs, eg. /about served from about.html
+		filename = filename + ".html"
+	default:
+		return "", nil, err
+	}
+
+	return filename, &base, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+// fakeSite is a static site build, mapping file paths to their contents
+var fakeSite = map[string]string{
+	"index.html":          `<a href="/docs/">Docs</a><a href="about">About</a>`,
+	"about.html":          `<a href="/">Home</a><img src="/logo.png">`,
+	"logo.png":            `not really a png`,
+	"docs/index.html":     `<a href="api/">API</a><a href="../">Home</a>`,
+	"docs/api/index.html": `<a href="v1.html">v1</a>`,
+	"docs/api/v1.html":    `<a href="../">API</a>`,
+}
+
+func TestFileFetcherSuccess(t *testing.T) {
+	root := writeFakeSite(t)
+	defer os.RemoveAll(root)
+
+	f := &FileFetcher{Root: root, Host: "example.com"}
+
+	testCases := map[string][]string{
+		"http://example.com":              []string{"http://example.com/docs/", "http://example.com/about"},
+		"http://example.com/":             []string{"http://example.com/docs/", "http://example.com/about"},
+		"http://example.com/about":        []string{"http://example.com/"},
+		"http://example.com/about.html":   []string{"http://example.com/"},
+		"http://example.com/docs/":        []string{"http://example.com/docs/api/", "http://example.com/"},
+		"http://example.com/docs/api":     []string{"http://example.com/docs/api/v1.html"},
+		"http://example.com/docs/api/":    []string{"http://example.com/docs/api/v1.html"},
+		"http://example.com/docs/../docs": []string{"http://example.com/docs/api/", "http://example.com/"},
+		"http://example.com/logo.png":     []string{},
+	}
+
+	for target, expected := range testCases {
+		page, err := f.Fetch(strToUrl(target))
+		assert.Nil(t, err, target)
+
+		links := linksToStrings(page.Links)
+		sort.Strings(links)
+		sort.Strings(expected)
+		assert.Equal(t, expected, links, target)
+	}
+
+	// Assets should resolve in the same way as links
+	page, err := f.Fetch(strToUrl("http://example.com/about"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"http://example.com/logo.png"}, assetsToStrings(page.Assets))
+}
+
+func TestFileFetcherNotFound(t *testing.T) {
+	root := writeFakeSite(t)
+	defer os.RemoveAll(root)
+
+	f := &FileFetcher{Root: root, Host: "example.com"}
+
+	testCases := []string{
+		"http://example.com/missing",
+		"http://example.com/missing.html",
+		"http://example.com/about.html/",
+		"http://example.com/../../etc/passwd",
+	}
+
+	for _, target := range testCases {
+		_, err := f.Fetch(strToUrl(target))
+		assert.True(t, os.IsNotExist(err), target)
+	}
+
+	// Other hosts are never served from our root
+	_, err := f.Fetch(strToUrl("http://elsewhere.com/"))
+	assert.Equal(t, UnknownHost, err)
+}
+
+// writeFakeSite writes fakeSite into a temporary directory
+func writeFakeSite(t *testing.T) string {
+	root, err := ioutil.TempDir("", "kraken")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range fakeSite {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func strToUrl(s string) *url.URL {
+	u, _ := url.Parse(s)
+	return u
+}
+
+func linksToStrings(links []*domain.Link) []string {
+	ret := make([]string, len(links))
+
+	for i, l := range links {
+		ret[i] = l.Target.String()
+	}
+
+	return ret
+}
+
+func assetsToStrings(assets []*domain.Asset) []string {
+	ret := make([]string, len(assets))
+
+	for i, a := range assets {
+		ret[i] = a.Url.String()
+	}
+
+	return ret
+}
+
+func urlsToStrings(urls []*url.URL) []string {
+	ret := make([]string, len(urls))
+
+	for i, u := range urls {
+		ret[i] = u.String()
+	}
+
+	return ret
+}
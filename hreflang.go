@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+// extractAlternates finds the alternate language versions of a page,
+// annotated with hreflang in link elements or the Link header
+func (e *extractor) extractAlternates(doc *goquery.Document, header http.Header) []*domain.Alternate {
+	alternates := make([]*domain.Alternate, 0)
+	seen := make(map[string]bool)
+
+	add := func(lang, href string) {
+		lang, href = strings.TrimSpace(lang), strings.TrimSpace(href)
+		if lang == "" || href == "" {
+			return
+		}
+
+		uri := e.normaliseUrl(doc.Url, href)
+		if uri == nil || seen[lang+" "+uri.String()] {
+			return
+		}
+
+		seen[lang+" "+uri.String()] = true
+		alternates = append(alternates, &domain.Alternate{
+			Lang: lang,
+			Url:  uri,
+		})
+	}
+
+	for _, n := range doc.Find("link").Nodes {
+		if hasRel(attr(n, "rel"), "alternate") {
+			add(attr(n, "hreflang"), attr(n, "href"))
+		}
+	}
+
+	for _, v := range header["Link"] {
+		for _, hl := range parseLinkHeader(v) {
+			if hasRel(hl.rel, "alternate") {
+				add(hl.hreflang, hl.href)
+			}
+		}
+	}
+
+	return alternates
+}
+
+// hasRel determines if a space separated list of relations includes rel
+func hasRel(rels, rel string) bool {
+	for _, r := range strings.Fields(rels) {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+const hreflangPage = `<html>
+<head>
+	<link rel="alternate" hreflang="en-GB" href="/en-gb/">
+	<link rel="alternate" hreflang="fr" href="http://example.fr/">
+	<link rel="alternate" hreflang="fr" href="http://example.fr/">
+	<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+</head>
+<body></body>
+</html>`
+
+func TestExtractAlternates(t *testing.T) {
+	e := &extractor{}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(hreflangPage))
+	assert.Nil(t, err)
+	doc.Url = strToUrl("http://example.com/en-gb/")
+
+	header := http.Header{}
+	header.Add("Link", `<http://example.com/>; rel="alternate"; hreflang="x-default"`)
+
+	page, err := e.extract(doc, header)
+	assert.Nil(t, err)
+
+	found := make([]string, len(page.Alternates))
+	for i, a := range page.Alternates {
+		found[i] = a.Lang + " " + a.Url.String()
+	}
+	assert.Equal(t, []string{
+		"en-GB http://example.com/en-gb/",
+		"fr http://example.fr/",
+		"x-default http://example.com/",
+	}, found)
+
+	// Alternates are followed, but not also as header links
+	found = make([]string, len(page.Links))
+	for i, l := range page.Links {
+		found[i] = l.Type + " " + l.Target.String()
+	}
+	assert.Equal(t, []string{
+		domain.LinkHreflang + " http://example.com/en-gb/",
+		domain.LinkHreflang + " http://example.fr/",
+		domain.LinkHreflang + " http://example.com/",
+	}, found)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	html "code.google.com/p/go.net/html"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+// resourceRels are Link header relations which refer to resources
+// used by a page, rather than to other pages
+var resourceRels = map[string]bool{
+	"dns-prefetch":  true,
+	"icon":          true,
+	"modulepreload": true,
+	"preconnect":    true,
+	"prefetch":      true,
+	"preload":       true,
+	"stylesheet":    true,
+}
+
+// extractHeaderLinks returns the pages referred to by Link headers,
+// resolved against the page they were served with
+func (e *extractor) extractHeaderLinks(parent *url.URL, header http.Header) []*domain.Link {
+	links := make([]*domain.Link, 0)
+
+	for _, v := range header["Link"] {
+		for _, hl := range parseLinkHeader(v) {
+			// Alternate languages are extracted separately
+			if isResourceRel(hl.rel) || hl.hreflang != "" {
+				continue
+			}
+
+			linkType := paginationType(hl.rel)
+			if linkType == "" {
+				linkType = domain.LinkHeader
+			}
+
+			if uri := e.normaliseUrl(parent, hl.href); uri != nil {
+				links = append(links, &domain.Link{
+					Target: uri,
+					Type:   linkType,
+				})
+			}
+		}
+	}
+
+	return links
+}
+
+// dedupeLinks removes links to the same target found in the same way,
+// while keeping those found in different ways, eg. a URL which is both
+// linked to and the target of a meta refresh
+func (e *extractor) dedupeLinks(original []*domain.Link) []*domain.Link {
+	seen := make(map[string]bool)
+	ret := make([]*domain.Link, 0)
+
+	for _, l := range original {
+		key := l.Type + " " + l.Target.String()
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		ret = append(ret, l)
+	}
+
+	return ret
+}
+
+// headerLink is a single link from a Link header
+type headerLink struct {
+	href     string
+	rel      string
+	hreflang string
+}
+
+// parseLinkHeader parses the value of a Link header, which holds a comma
+// separated list of links such as: <http://example.com/2>; rel="next"
+func parseLinkHeader(v string) []*headerLink {
+	ret := make([]*headerLink, 0)
+
+	for {
+		start := strings.Index(v, "<")
+		if start < 0 {
+			return ret
+		}
+		end := strings.Index(v[start:], ">")
+		if end < 0 {
+			return ret
+		}
+		end += start
+
+		hl := &headerLink{
+			href: strings.TrimSpace(v[start+1 : end]),
+		}
+
+		// Parameters run until the next link, though quoted values may
+		// themselves contain commas
+		v = v[end+1:]
+		params, rest := splitLinkParams(v)
+		v = rest
+
+		for _, p := range strings.Split(params, ";") {
+			i := strings.Index(p, "=")
+			if i < 0 {
+				continue
+			}
+			val := strings.Trim(strings.TrimSpace(p[i+1:]), `"`)
+			switch strings.ToLower(strings.TrimSpace(p[:i])) {
+			case "rel":
+				hl.rel = val
+			case "hreflang":
+				hl.hreflang = val
+			}
+		}
+
+		ret = append(ret, hl)
+	}
+}
+
+// splitLinkParams splits the parameters of a link from any following links
+func splitLinkParams(v string) (string, string) {
+	quoted := false
+	for i, c := range v {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			return v[:i], v[i+1:]
+		}
+	}
+
+	return v, ""
+}
+
+// parseMetaRefresh extracts the URL from the content of a meta refresh,
+// eg. 0;url=http://example.com/ or 5; URL='/next'
+func parseMetaRefresh(content string) string {
+	i := strings.IndexAny(content, ";,")
+	if i < 0 {
+		// Just a delay, refreshing the same page
+		return ""
+	}
+
+	target := strings.TrimSpace(content[i+1:])
+	if len(target) >= 4 && strings.EqualFold(target[:3], "url") {
+		if rest := strings.TrimSpace(target[3:]); strings.HasPrefix(rest, "=") {
+			target = strings.TrimSpace(rest[1:])
+		}
+	}
+
+	return strings.Trim(target, `"'`)
+}
+
+// paginationType returns the type of a pagination link with the
+// specified rel, or an empty string if this isn't pagination
+func paginationType(rel string) string {
+	for _, r := range strings.Fields(strings.ToLower(rel)) {
+		switch r {
+		case "next":
+			return domain.LinkNext
+		case "prev", "previous":
+			return domain.LinkPrev
+		}
+	}
+
+	return ""
+}
+
+// isResourceRel determines if a rel refers to a resource used by a page
+func isResourceRel(rel string) bool {
+	for _, r := range strings.Fields(strings.ToLower(rel)) {
+		if resourceRels[r] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// attr returns the value of the named attribute of a node
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
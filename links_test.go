@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+const linkSourcesPage = `<html>
+<head>
+	<meta http-equiv="Refresh" content="5; URL='/moved'">
+	<link rel="next" href="/page/3">
+	<link rel="stylesheet" href="/style.css">
+</head>
+<body>
+	<a href="/about">About <em>the
+		Kraken</em></a>
+	<a rel="prev" href="/page/1">Previous</a>
+	<map><area shape="rect" coords="0,0,10,10" href="/map/north" alt="North"></map>
+	<form action="/search"><input name="q"></form>
+	<form action="/login" method="POST"><input name="user"></form>
+	<form method="get"><input name="nowhere"></form>
+	<a href="/moved">Moved</a>
+</body>
+</html>`
+
+func TestExtractLinkSources(t *testing.T) {
+	e := &extractor{}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(linkSourcesPage))
+	assert.Nil(t, err)
+	doc.Url = strToUrl("http://example.com/page/2")
+
+	header := http.Header{}
+	header.Add("Link", `</page/3>; rel="next", </fonts/a.woff2>; rel=preload; as=font`)
+	header.Add("Link", `<http://example.com/page/2?canonical=1>; rel="canonical"`)
+
+	page, err := e.extract(doc, header)
+	assert.Nil(t, err)
+
+	found := make([]string, len(page.Links))
+	for i, l := range page.Links {
+		found[i] = l.Type + " " + l.Target.String()
+	}
+
+	assert.Equal(t, []string{
+		domain.LinkAnchor + " http://example.com/about",
+		domain.LinkPrev + " http://example.com/page/1",
+		domain.LinkArea + " http://example.com/map/north",
+		domain.LinkAnchor + " http://example.com/moved",
+		domain.LinkNext + " http://example.com/page/3",
+		domain.LinkForm + " http://example.com/search",
+		domain.LinkRefresh + " http://example.com/moved",
+		domain.LinkHeader + " http://example.com/page/2?canonical=1",
+	}, found)
+}
+
+func TestExtractLinkText(t *testing.T) {
+	e := &extractor{}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(linkSourcesPage))
+	assert.Nil(t, err)
+	doc.Url = strToUrl("http://example.com/page/2")
+
+	links, err := e.extractLinks(doc)
+	assert.Nil(t, err)
+
+	found := make(map[string]string)
+	for _, l := range links {
+		found[l.Type+" "+l.Target.String()] = l.Text
+	}
+
+	assert.Equal(t, "About the Kraken", found[domain.LinkAnchor+" http://example.com/about"])
+	assert.Equal(t, "North", found[domain.LinkArea+" http://example.com/map/north"])
+	assert.Equal(t, "", found[domain.LinkForm+" http://example.com/search"])
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	links := parseLinkHeader(`<http://example.com/a,b>; rel="next"; title="One, two", <http://example.com/c>;REL=prev,<broken`)
+
+	assert.Len(t, links, 2)
+	assert.Equal(t, "http://example.com/a,b", links[0].href)
+	assert.Equal(t, "next", links[0].rel)
+	assert.Equal(t, "http://example.com/c", links[1].href)
+	assert.Equal(t, "prev", links[1].rel)
+}
+
+func TestParseMetaRefresh(t *testing.T) {
+	testCases := map[string]string{
+		"0;url=http://example.com/": "http://example.com/",
+		"5; URL='/next'":            "/next",
+		`3;url="/quoted"`:           "/quoted",
+		"0; /no-prefix":             "/no-prefix",
+		"1, url=/comma":             "/comma",
+		"0;urlshortener.html":       "urlshortener.html",
+		"30":                        "",
+		"":                          "",
+	}
+
+	for tc, expected := range testCases {
+		assert.Equal(t, expected, parseMetaRefresh(tc), tc)
+	}
+}
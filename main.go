@@ -1,16 +1,30 @@
 package main
 
 import (
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	log "github.com/cihub/seelog"
+	_ "modernc.org/sqlite"
 
+	"github.com/mattheath/kraken/analysis"
+	"github.com/mattheath/kraken/check"
 	"github.com/mattheath/kraken/crawler"
+	"github.com/mattheath/kraken/domain"
+	"github.com/mattheath/kraken/export"
+	"github.com/mattheath/kraken/middleware"
 	"github.com/mattheath/kraken/sitemap"
+	"github.com/mattheath/kraken/warc"
 )
 
 var (
@@ -20,16 +34,83 @@ var (
 	depth          = flagSet.Int("depth", 4, "depth of pages to crawl")
 	verboseLogging = flagSet.Bool("v", false, "enable verbose logging")
 	outputDir      = flagSet.String("o", "", "directory to output to")
+	rootDir        = flagSet.String("root", "", "directory to serve the target from, instead of the network")
+	warcOut        = flagSet.String("warc", "", "WARC file to record all requests and responses to")
+	warcReplay     = flagSet.String("replay", "", "WARC file to replay responses from, instead of the network")
+	authMethod     = flagSet.String("auth", "", "authentication method: basic, bearer or form")
+	authFile       = flagSet.String("auth-file", "", "file of KEY=value credentials, overriding the environment")
+	loginUrl       = flagSet.String("login-url", "", "URL to post the login form to, for form authentication")
+	loginUserField = flagSet.String("login-user-field", "username", "login form field for the username")
+	loginPassField = flagSet.String("login-pass-field", "password", "login form field for the password")
+	seedSitemaps   = flagSet.Bool("sitemaps", false, "also crawl URLs listed in sitemaps declared in robots.txt or at /sitemap.xml")
+	cachePages     = flagSet.Bool("cache", false, "cache fetched pages in memory, so each URL is only fetched once")
+	retries        = flagSet.Int("retries", 0, "number of times to retry failed fetches")
+	retryBackoff   = flagSet.Duration("retry-backoff", time.Second, "delay before the first retry, doubling each time")
+	rateLimit      = flagSet.Float64("rate", 0, "maximum fetches per second, unlimited if 0")
+	priorityFrom   = flagSet.String("priority", sitemap.PriorityDepth, "derive sitemap priority from page depth, inlinks or pagerank")
+	sitemapRules   = flagSet.String("sitemap-rules", "", "JSON file of rules overriding sitemap changefreq and priority by path")
+	gzipSitemaps   = flagSet.Bool("gzip", false, "gzip XML sitemaps as they are written")
+	sitemapImages  = flagSet.Bool("sitemap-images", false, "list the images on each page in XML sitemaps")
+	sitemapVideos  = flagSet.Bool("sitemap-videos", false, "list the videos on each page in XML sitemaps")
+	sitemapBase    = flagSet.String("sitemap-base", "", "URL sitemaps will be hosted at, referenced by the sitemap index, defaults to the target's root")
+	graphFormats   = flagSet.String("graph", "", "comma separated link graph formats to write: dot, graphml or gexf")
+	tableFormat    = flagSet.String("tables", "", "also write pages, edges and assets as flat tables: csv or tsv")
+	pageColumns    = flagSet.String("page-columns", "", "comma separated columns of the pages table, defaults to all")
+	edgeColumns    = flagSet.String("edge-columns", "", "comma separated columns of the edges table, defaults to all")
+	assetColumns   = flagSet.String("asset-columns", "", "comma separated columns of the assets table, defaults to all")
+	depthReport    = flagSet.Bool("depth-report", false, "report pages which are many clicks deep, unreachable from the target, or orphaned")
+	maxClickDepth  = flagSet.Int("max-click-depth", 3, "click depth beyond which pages are reported as deep")
+	urlList        = flagSet.String("url-list", "", "file of URLs, one per line, to report as orphaned if nothing links to them")
+	brokenLinks    = flagSet.Bool("broken-links", false, "report every broken page along with the links to it")
+	sqliteOut      = flagSet.Bool("sqlite", false, "also write pages, links, assets, errors and headers to a SQLite database")
+	sortOrder      = flagSet.String("sort", "", "sort output by url, or by depth then url, so crawls can be compared")
+	jsonLines      = flagSet.String("jsonl", "", "file to stream each page to as JSON Lines as soon as it is crawled, or - for stdout")
+	obeyRobots     = flagSet.Bool("robots", false, "don't crawl pages disallowed by robots.txt")
+	htmlTree       = flagSet.Bool("tree", false, "also write a collapsible HTML tree of the site's pages")
+	checkRules     = flagSet.String("check-rules", check.DefaultRules, "comma separated rules to check, in check mode")
+	baselineFile   = flagSet.String("baseline", "", "file of known violations which don't fail a check")
+	writeBaseline  = flagSet.String("write-baseline", "", "file to write a baseline of every violation found to, in check mode")
+	jsonReport     = flagSet.Bool("json", false, "print reports, such as audits and diffs, as JSON rather than text")
+	faultRate      = flagSet.Float64("fault-rate", 0, "proportion of fetches to fail deliberately, between 0 and 1, for testing")
+)
+
+// Modes kraken can run in, other than writing sitemaps, named before
+// any flags, eg. kraken audit-sitemap -target="http://example.com"
+const (
+	modeAuditSitemap = "audit-sitemap"
+	modeDiff         = "diff"
+	modeCheck        = "check"
 )
 
 func main() {
-	// Process flags
-	flagSet.Parse(os.Args[1:])
+	// Process our mode and flags
+	mode, args := "", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		mode, args = args[0], args[1:]
+	}
+	flagSet.Parse(args)
+
+	switch mode {
+	case "", modeAuditSitemap, modeDiff, modeCheck:
+	default:
+		fmt.Printf("Unknown mode '%s', must be %s, %s or %s\n", mode, modeAuditSitemap, modeDiff, modeCheck)
+		os.Exit(1)
+	}
 
 	// Flush logs before exit
-	setLogger(*verboseLogging)
+	setLogger(*verboseLogging, *jsonLines == "-" || *jsonReport)
 	defer log.Flush()
 
+	// Compare the output of two earlier crawls
+	if mode == modeDiff {
+		if flagSet.NArg() != 2 {
+			fmt.Println("Please specify two JSON site descriptions, eg. kraken diff old.json new.json")
+			os.Exit(1)
+		}
+		diffCrawls(flagSet.Arg(0), flagSet.Arg(1))
+		return
+	}
+
 	// Do we have a target?
 	if *target == "" {
 		fmt.Println("Please specify a target domain, eg. kraken -target=\"http://example.com\"")
@@ -37,7 +118,7 @@ func main() {
 	}
 	targetUrl, err := url.Parse(*target)
 	if err != nil {
-		fmt.Println("Could not parse target url '%s' - %v", *target, err)
+		fmt.Printf("Could not parse target url '%s' - %v\n", *target, err)
 		os.Exit(1)
 	}
 
@@ -50,64 +131,588 @@ func main() {
 		}
 	}
 
-	// Use a HTTP based fetcher
-	fetcher := &HttpFetcher{}
+	// Check our output options before spending time crawling
+	if _, err := graphFormatList(); err != nil {
+		log.Critical(err)
+		os.Exit(1)
+	}
+	if _, err := tableList(); err != nil {
+		log.Critical(err)
+		os.Exit(1)
+	}
+	var rules []*check.Rule
+	var baseline *check.Baseline
+	if mode == modeCheck {
+		rules, baseline, err = checkOptions()
+		if err != nil {
+			log.Criticalf("Invalid check options: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	var expected []string
+	if *urlList != "" {
+		expected, err = readUrlList(*urlList, targetUrl)
+		if err != nil {
+			log.Criticalf("Failed to read URL list %s: %v", *urlList, err)
+			os.Exit(1)
+		}
+	}
+
+	// Choose how we fetch pages
+	fetcher, closeFetcher, err := newFetcher(targetUrl)
+	if err != nil {
+		log.Criticalf("Failed to initialise fetcher: %v", err)
+		os.Exit(1)
+	}
 
 	// Fire!
 	log.Infof("Unleashing the Kraken at %s", *target)
 
-	// Crawl the specified site
 	c := crawler.NewCrawler()
-	c.Work(targetUrl, *depth, fetcher)
+	if err := c.SetOrder(*sortOrder); err != nil {
+		log.Criticalf("Invalid sort order '%s': %v", *sortOrder, err)
+		os.Exit(1)
+	}
+
+	// Our target's robots.txt, which we may obey, and which declares
+	// where the site's sitemaps are
+	readSitemaps := mode == modeAuditSitemap || *seedSitemaps
+	retriever, canRetrieve := fetcher.(Retriever)
+	robots := &robotsRules{}
+	if canRetrieve && (*obeyRobots || readSitemaps) {
+		robots = retrieveRobots(retriever, targetUrl)
+	}
+
+	// URLs listed in the site's sitemaps, which we either audit against
+	// what we can reach by crawling, or seed the crawl with, as these
+	// may not be linked to from anywhere else
+	var listed []*url.URL
+	if readSitemaps {
+		if !canRetrieve {
+			log.Critical("Sitemaps can't be retrieved by this fetcher")
+			os.Exit(1)
+		}
+		listed = discoverSitemapUrls(retriever, targetUrl, robots)
+		log.Infof("%v URLs found in sitemaps", len(listed))
+	}
+	if mode != modeAuditSitemap {
+		for _, u := range listed {
+			c.Seed(u, domain.SourceSitemap)
+		}
+	}
+
+	// Stream pages out as they're crawled
+	streamPage, closeJSONLines, err := streamJSONLines()
+	if err != nil {
+		log.Criticalf("Failed to open JSON Lines output: %v", err)
+		os.Exit(1)
+	}
+	if streamPage != nil {
+		c.OnPage(streamPage)
+	}
+
+	// Crawl the specified site
+	timings := &middleware.Timings{}
+	mw := newMiddleware(timings)
+	if *obeyRobots {
+		mw = append([]middleware.Middleware{middleware.Robots(robots.allowed)}, mw...)
+	}
+	chain := middleware.Chain(fetcher, mw...)
+	c.Work(targetUrl, *depth, chain)
+
+	if mode == modeAuditSitemap {
+		auditSitemap(out, c, listed, chain)
+	}
+
+	closeFetcher()
+	closeJSONLines()
 
 	// Success
 	log.Infof("%v pages found, %v requests attempted", len(c.Pages), c.TotalRequests())
+	log.Infof("%v fetches took %v on average, %v at most", timings.Count, timings.Mean(), timings.Max)
 
+	checkHreflang(c)
+	reportBrokenLinks(out, c)
+	reportDepth(out, c, append(urlStrings(listed), expected...))
 	writeSitemaps(out, c)
+	writeGraphs(out, c)
+	writeTables(out, c)
+	writeSQLite(out, c)
+
+	// Fail if we find problems, eg. to stop a deploy
+	if mode == modeCheck && !checkCrawl(out, c, rules, baseline) {
+		log.Flush()
+		os.Exit(2)
+	}
 }
 
-// setLogger initialises the logger with the desired verbosity level
-func setLogger(verbose bool) {
-	var logLevel string
-	if verbose {
-		logLevel = "debug"
+// streamJSONLines returns a handler writing each page as JSON Lines as
+// soon as it is crawled, if selected by our flags, along with a function
+// to close the output once the crawl is complete
+func streamJSONLines() (crawler.PageHandler, func(), error) {
+	if *jsonLines == "" {
+		return nil, func() {}, nil
+	}
+
+	out, closer := os.Stdout, func() {}
+	if *jsonLines != "-" {
+		f, err := os.Create(*jsonLines)
+		if err != nil {
+			return nil, nil, err
+		}
+		out, closer = f, func() {
+			if err := f.Close(); err != nil {
+				log.Errorf("Failed to close JSON Lines file %s: %v", *jsonLines, err)
+			}
+			log.Infof("Wrote JSON Lines to %s", *jsonLines)
+		}
+	}
+
+	w := sitemap.NewJSONLinesWriter(out)
+	handler := func(p *domain.Page) {
+		if err := w.WritePage(p); err != nil {
+			log.Errorf("Failed to write %s as JSON Lines: %v", p.Url, err)
+		}
+	}
+
+	return handler, closer, nil
+}
+
+// auditSitemap compares the URLs listed in the site's sitemaps with the
+// pages we crawled, fetching any we didn't reach to check they respond OK
+func auditSitemap(outdir string, c crawler.Crawler, listed []*url.URL, fetcher crawler.Fetcher) {
+	a := sitemap.AuditSitemap(urlStrings(listed), c.AllDiscovered(), func(u string) (int, error) {
+		target, err := url.Parse(u)
+		if err != nil {
+			return 0, err
+		}
+		page, err := fetcher.Fetch(target)
+		if err != nil {
+			return 0, err
+		}
+		if page.RedirectStatus != 0 {
+			return page.RedirectStatus, nil
+		}
+		return page.Status, nil
+	})
+
+	writeReport(outdir, c, "sitemap-audit", "sitemap audit", a, a.WriteText)
+}
+
+// reportDepth reports pages which are many clicks from the target or
+// can't be reached from it, and listed URLs which nothing links to,
+// if selected by our flags
+func reportDepth(outdir string, c crawler.Crawler, listed []string) {
+	if !*depthReport {
+		return
+	}
+
+	r := analysis.AnalyseDepth(c.Target(), c.AllDiscovered(), *maxClickDepth, listed)
+	writeReport(outdir, c, "depth", "depth report", r, r.WriteText)
+}
+
+// readUrlList reads a file of URLs, one per line, which may be
+// relative to our target. Blank lines and lines starting with # are
+// ignored.
+func readUrlList(file string, target *url.URL) ([]string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]string, 0)
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		u, err := target.Parse(line)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, u.String())
+	}
+
+	return ret, nil
+}
+
+func urlStrings(urls []*url.URL) []string {
+	ret := make([]string, len(urls))
+	for i, u := range urls {
+		ret[i] = u.String()
+	}
+
+	return ret
+}
+
+// reportBrokenLinks reports pages which are broken, along with the
+// links to them, if selected by our flags
+func reportBrokenLinks(outdir string, c crawler.Crawler) {
+	broken := sitemap.FindBrokenTargets(c.AllDiscovered())
+	if len(broken) > 0 {
+		log.Warnf("%v broken pages found", len(broken))
+	}
+
+	if !*brokenLinks {
+		return
+	}
+
+	writeReport(outdir, c, "broken-links", "broken link report", broken, func(w io.Writer) error {
+		return sitemap.WriteBrokenTargets(w, broken)
+	})
+}
+
+// writeReport prints a report as text, or JSON if selected by our flags,
+// and writes it as JSON to the output directory
+func writeReport(outdir string, c crawler.Crawler, suffix, name string, report interface{}, text func(io.Writer) error) {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Criticalf("Failed to build %s: %v", name, err)
+		os.Exit(1)
+	}
+
+	// Keep stdout clear for JSON Lines if it's in use
+	var w io.Writer = os.Stdout
+	if *jsonLines == "-" {
+		w = os.Stderr
+	}
+
+	if *jsonReport {
+		_, err = fmt.Fprintf(w, "%s\n", b)
 	} else {
-		logLevel = "info"
+		err = text(w)
+	}
+	if err != nil {
+		log.Errorf("Failed to write %s: %v", name, err)
+	}
+
+	reportout := fmt.Sprintf("%s/%s-%s.json", outdir, c.Target().Host, suffix)
+	if err := ioutil.WriteFile(reportout, b, 0644); err != nil {
+		log.Criticalf("Failed to write %s to %s: %v", name, reportout, err)
+		os.Exit(1)
+	}
+	log.Infof("Wrote %s to %s", name, reportout)
+}
+
+// checkOptions returns the rules to check and any baseline of
+// known violations, selected by our flags
+func checkOptions() ([]*check.Rule, *check.Baseline, error) {
+	rules, err := check.SelectRules(*checkRules)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	logConfig := `
-<seelog>
-    <outputs>
-        <filter levels="%s">
-            <console />
-        </filter>
-    </outputs>
-</seelog>`
+	if *baselineFile == "" {
+		return rules, nil, nil
+	}
+
+	f, err := os.Open(*baselineFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	baseline, err := check.ReadBaseline(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to read baseline %s: %v", *baselineFile, err)
+	}
+
+	return rules, baseline, nil
+}
 
-	logger, _ := log.LoggerFromConfigAsBytes([]byte(fmt.Sprintf(logConfig, logLevel)))
+// checkCrawl checks every page discovered against our rules, writing
+// JUnit and SARIF reports, and returns whether the check passed
+func checkCrawl(outdir string, c crawler.Crawler, rules []*check.Rule, baseline *check.Baseline) bool {
+	res := check.Run(rules, c.AllDiscovered(), baseline)
+
+	for _, r := range []struct {
+		name  string
+		file  string
+		write func(io.Writer, *check.Result) error
+	}{
+		{"JUnit report", fmt.Sprintf("%s/%s-check.junit.xml", outdir, c.Target().Host), check.WriteJUnit},
+		{"SARIF report", fmt.Sprintf("%s/%s-check.sarif", outdir, c.Target().Host), check.WriteSARIF},
+	} {
+		f, err := os.Create(r.file)
+		if err == nil {
+			err = r.write(f, res)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			log.Criticalf("Failed to write %s to %s: %v", r.name, r.file, err)
+			os.Exit(1)
+		}
+		log.Infof("Wrote %s to %s", r.name, r.file)
+	}
+
+	if *writeBaseline != "" {
+		f, err := os.Create(*writeBaseline)
+		if err == nil {
+			err = check.WriteBaseline(f, res.Violations)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			log.Criticalf("Failed to write baseline to %s: %v", *writeBaseline, err)
+			os.Exit(1)
+		}
+		log.Infof("Wrote baseline of %v violations to %s", len(res.Violations), *writeBaseline)
+	}
+
+	// Keep stdout clear for JSON Lines if it's in use
+	var w io.Writer = os.Stdout
+	if *jsonLines == "-" {
+		w = os.Stderr
+	}
+	if err := res.WriteText(w); err != nil {
+		log.Errorf("Failed to write check results: %v", err)
+	}
+
+	return !res.Failed()
+}
+
+// diffCrawls prints the differences between two JSON site descriptions
+func diffCrawls(oldFile, newFile string) {
+	oldf, err := os.Open(oldFile)
+	if err != nil {
+		log.Criticalf("Failed to open %s: %v", oldFile, err)
+		os.Exit(1)
+	}
+	defer oldf.Close()
+
+	newf, err := os.Open(newFile)
+	if err != nil {
+		log.Criticalf("Failed to open %s: %v", newFile, err)
+		os.Exit(1)
+	}
+	defer newf.Close()
+
+	d, err := sitemap.DiffJSONSiteStructures(oldf, newf)
+	if err != nil {
+		log.Criticalf("Failed to compare %s with %s: %v", oldFile, newFile, err)
+		os.Exit(1)
+	}
+
+	if *jsonReport {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(d)
+	} else {
+		err = d.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Errorf("Failed to write diff: %v", err)
+	}
+}
+
+// checkHreflang reports invalid or unreciprocated hreflang
+// annotations found during the crawl
+func checkHreflang(c crawler.Crawler) {
+	issues := sitemap.ValidateHreflang(c.AllPages())
+	for _, i := range issues {
+		log.Warnf("Hreflang problem on %s", i)
+	}
+
+	if len(issues) > 0 {
+		log.Infof("%v hreflang problems found", len(issues))
+	}
+}
+
+// newFetcher returns the fetcher selected by our flags, along with a
+// function to release any files it holds once the crawl is complete.
+// We use a HTTP based fetcher, unless we're crawling a local directory
+// or replaying a WARC file.
+func newFetcher(target *url.URL) (crawler.Fetcher, func(), error) {
+	switch {
+	case *warcReplay != "":
+		f, err := os.Open(*warcReplay)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer f.Close()
+
+		var r io.Reader = f
+		if strings.HasSuffix(*warcReplay, ".gz") {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			r = gz
+		}
+
+		fetcher, err := NewWarcFetcher(r)
+		return fetcher, func() {}, err
+
+	case *rootDir != "":
+		fetcher := &FileFetcher{
+			Root: *rootDir,
+			Host: target.Host,
+		}
+		return fetcher, func() {}, nil
+
+	}
+
+	fetcher := &HttpFetcher{
+		Client: &http.Client{
+			CheckRedirect: checkRedirect,
+		},
+	}
+
+	// Log in to the site if required
+	auth, err := newAuthenticator(target)
+	if err == nil {
+		fetcher.Auth = auth
+		fetcher.AuthHosts = []string{target.Host}
+		if f, ok := auth.(*FormAuth); ok {
+			fetcher.AuthHosts = append(fetcher.AuthHosts, f.LoginUrl.Host)
+		}
+		err = fetcher.Login()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Record everything our client sends and receives from here on,
+	// after logging in so login forms and passwords aren't archived
+	closer := func() {}
+	if *warcOut != "" {
+		f, err := os.Create(*warcOut)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		fetcher.Client.Transport = &warc.Recorder{
+			Writer: warc.NewWriter(f),
+		}
+		closer = func() {
+			if err := f.Close(); err != nil {
+				log.Errorf("Failed to close WARC file %s: %v", *warcOut, err)
+			}
+			log.Infof("Wrote WARC to %s", *warcOut)
+		}
+	}
+
+	return fetcher, closer, nil
+}
+
+// newMiddleware returns the stack of middleware selected by our flags,
+// outermost first. Cached pages skip rate limiting, while each retry is
+// rate limited, and injected faults can be recovered from by retrying.
+func newMiddleware(timings *middleware.Timings) []middleware.Middleware {
+	mw := []middleware.Middleware{
+		middleware.Logging(),
+		middleware.Timing(timings),
+	}
+
+	if *cachePages {
+		mw = append(mw, middleware.Cache())
+	}
+	if *retries > 0 {
+		mw = append(mw, middleware.Retry(*retries, *retryBackoff))
+	}
+	if *rateLimit > 0 {
+		mw = append(mw, middleware.RateLimit(*rateLimit))
+	}
+	if *faultRate > 0 {
+		mw = append(mw, middleware.FaultInjection(*faultRate, time.Now().UnixNano()))
+	}
+
+	return mw
+}
+
+// newAuthenticator returns the Authenticator selected by our flags, if any
+func newAuthenticator(target *url.URL) (Authenticator, error) {
+	if *authMethod == "" {
+		return nil, nil
+	}
+
+	creds, err := ReadCredentials(*authFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve the login URL against our target, so it can be relative
+	var login *url.URL
+	if *loginUrl != "" {
+		u, err := url.Parse(*loginUrl)
+		if err != nil {
+			return nil, err
+		}
+		login = target.ResolveReference(u)
+	}
+
+	return NewAuthenticator(*authMethod, creds, login, *loginUserField, *loginPassField)
+}
+
+// setLogger initialises the logger with the desired verbosity level,
+// logging to stderr if stdout is in use for output
+func setLogger(verbose, stderr bool) {
+	var logLevel log.LogLevel = log.InfoLvl
+	if verbose {
+		logLevel = log.DebugLvl
+	}
+
+	var out io.Writer = os.Stdout
+	if stderr {
+		out = os.Stderr
+	}
+
+	logger, _ := log.LoggerFromWriterWithMinLevel(out, logLevel)
 	log.UseLogger(logger)
 }
 
 func writeSitemaps(outdir string, c crawler.Crawler) error {
 
-	// Build sitemap and write to output file
-	xmlout := fmt.Sprintf("%s/%s-sitemap.xml", outdir, c.Target().Host)
-	xmlSitemap, err := sitemap.BuildXMLSitemap(c.AllPages())
+	opts, err := sitemapOptions()
 	if err != nil {
-		log.Criticalf("Failed to generate sitemap to %s", xmlout)
+		log.Criticalf("Invalid sitemap options: %v", err)
 		os.Exit(1)
 	}
 
-	if err := ioutil.WriteFile(xmlout, xmlSitemap, 0644); err != nil {
-		log.Criticalf("Failed to write sitemap to %s", xmlout)
+	ext := "xml"
+	if opts.Gzip {
+		ext = "xml.gz"
+	}
+
+	// Stream sitemaps into numbered output files, as many as we need
+	numbered := func(n int) string {
+		return fmt.Sprintf("%s-sitemap-%d.%s", c.Target().Host, n, ext)
+	}
+	create := func(n int) (io.WriteCloser, error) {
+		return os.Create(fmt.Sprintf("%s/%s", outdir, numbered(n)))
+	}
+	n, err := sitemap.WriteXMLSitemaps(c.AllPages(), opts, create, sitemap.MaxSitemapUrls, sitemap.MaxSitemapBytes)
+	if err != nil {
+		log.Criticalf("Failed to write sitemaps to %s: %v", outdir, err)
 		os.Exit(1)
 	}
-	log.Infof("Wrote XML sitemap to %s", xmlout)
+
+	// Everything fits in a single sitemap, so we don't need an index
+	if n == 1 {
+		xmlout := fmt.Sprintf("%s/%s-sitemap.%s", outdir, c.Target().Host, ext)
+		if err := os.Rename(fmt.Sprintf("%s/%s", outdir, numbered(1)), xmlout); err != nil {
+			log.Criticalf("Failed to write sitemap to %s", xmlout)
+			os.Exit(1)
+		}
+		log.Infof("Wrote XML sitemap to %s", xmlout)
+	} else {
+		log.Infof("Wrote %v XML sitemaps to %s", n, outdir)
+
+		names := make([]string, n)
+		for i := range names {
+			names[i] = numbered(i + 1)
+		}
+		writeSitemapIndex(outdir, c.Target(), names)
+	}
 
 	// Build JSON site description
 	siteout := fmt.Sprintf("%s/%s-sitemap.json", outdir, c.Target().Host)
 
-	b, err := sitemap.BuildJSONSiteStructure(c.Target(), c.AllPages())
+	b, err := sitemap.BuildJSONSiteStructure(c.Target(), c.AllDiscovered())
 
 	if err := ioutil.WriteFile(siteout, b, 0644); err != nil {
 		log.Criticalf("Failed to write sitemap to %s", siteout)
@@ -115,5 +720,249 @@ func writeSitemaps(outdir string, c crawler.Crawler) error {
 	}
 	log.Infof("Wrote JSON sitemap to %s", siteout)
 
+	// Build HTML tree of the site
+	if *htmlTree {
+		treeout := fmt.Sprintf("%s/%s-tree.html", outdir, c.Target().Host)
+
+		b, err := sitemap.BuildHTMLTree(c.Target(), c.AllPages())
+		if err == nil {
+			err = ioutil.WriteFile(treeout, b, 0644)
+		}
+		if err != nil {
+			log.Criticalf("Failed to write tree to %s: %v", treeout, err)
+			os.Exit(1)
+		}
+		log.Infof("Wrote HTML tree to %s", treeout)
+	}
+
 	return nil
 }
+
+// graphWriters write the link graph in each of our supported formats
+var graphWriters = map[string]func(io.Writer, *export.Graph) error{
+	"dot":     export.WriteDOT,
+	"graphml": export.WriteGraphML,
+	"gexf":    export.WriteGEXF,
+}
+
+// graphFormatList returns the graph formats selected by our flags
+func graphFormatList() ([]string, error) {
+	ret := make([]string, 0)
+	if *graphFormats == "" {
+		return ret, nil
+	}
+
+	for _, format := range strings.Split(*graphFormats, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if _, ok := graphWriters[format]; !ok {
+			return nil, fmt.Errorf("Unknown graph format '%s', must be dot, graphml or gexf", format)
+		}
+		ret = append(ret, format)
+	}
+
+	return ret, nil
+}
+
+// writeGraphs writes the link graph in each of the formats
+// selected by our flags
+func writeGraphs(outdir string, c crawler.Crawler) {
+	formats, _ := graphFormatList()
+	if len(formats) == 0 {
+		return
+	}
+
+	g := export.NewGraph(c.AllPages())
+	for _, format := range formats {
+		graphout := fmt.Sprintf("%s/%s-graph.%s", outdir, c.Target().Host, format)
+		f, err := os.Create(graphout)
+		if err == nil {
+			err = graphWriters[format](f, g)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			log.Criticalf("Failed to write graph to %s: %v", graphout, err)
+			os.Exit(1)
+		}
+		log.Infof("Wrote %s graph to %s", format, graphout)
+	}
+}
+
+// table is a flat file of rows, with the columns selected by our flags
+type table struct {
+	name    string
+	columns []string
+	rows    func([]*domain.Page) []export.Row
+}
+
+// tableDelimiters separate the columns in each of our table formats
+var tableDelimiters = map[string]rune{
+	"csv": ',',
+	"tsv": '\t',
+}
+
+// tableList returns the tables selected by our flags
+func tableList() ([]*table, error) {
+	ret := make([]*table, 0)
+	if *tableFormat == "" {
+		return ret, nil
+	}
+
+	if _, ok := tableDelimiters[*tableFormat]; !ok {
+		return nil, fmt.Errorf("Unknown table format '%s', must be csv or tsv", *tableFormat)
+	}
+
+	for _, t := range []struct {
+		name      string
+		available []string
+		selected  string
+		rows      func([]*domain.Page) []export.Row
+	}{
+		{"pages", export.PageColumns, *pageColumns, export.PageRows},
+		{"edges", export.EdgeColumns, *edgeColumns, export.EdgeRows},
+		{"assets", export.AssetColumns, *assetColumns, export.AssetRows},
+	} {
+		columns, err := export.SelectColumns(t.available, t.selected)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s columns: %v", t.name, err)
+		}
+		ret = append(ret, &table{
+			name:    t.name,
+			columns: columns,
+			rows:    t.rows,
+		})
+	}
+
+	return ret, nil
+}
+
+// writeTables writes pages, edges and assets as flat files,
+// in the format selected by our flags
+func writeTables(outdir string, c crawler.Crawler) {
+	tables, _ := tableList()
+	pages := c.AllPages()
+
+	for _, t := range tables {
+		tableout := fmt.Sprintf("%s/%s-%s.%s", outdir, c.Target().Host, t.name, *tableFormat)
+		f, err := os.Create(tableout)
+		if err == nil {
+			err = export.WriteTable(f, tableDelimiters[*tableFormat], t.columns, t.rows(pages))
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			log.Criticalf("Failed to write %s to %s: %v", t.name, tableout, err)
+			os.Exit(1)
+		}
+		log.Infof("Wrote %s to %s", t.name, tableout)
+	}
+}
+
+// writeSQLite writes every page discovered to a SQLite database,
+// if selected by our flags, replacing any from an earlier crawl
+func writeSQLite(outdir string, c crawler.Crawler) {
+	if !*sqliteOut {
+		return
+	}
+
+	dbout := fmt.Sprintf("%s/%s-crawl.sqlite", outdir, c.Target().Host)
+	if err := os.Remove(dbout); err != nil && !os.IsNotExist(err) {
+		log.Criticalf("Failed to replace %s: %v", dbout, err)
+		os.Exit(1)
+	}
+
+	db, err := sql.Open("sqlite", dbout)
+	if err == nil {
+		err = export.WriteSQLite(db, c.AllDiscovered())
+		if cerr := db.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		log.Criticalf("Failed to write SQLite database to %s: %v", dbout, err)
+		os.Exit(1)
+	}
+	log.Infof("Wrote SQLite database to %s", dbout)
+}
+
+// sitemapOptions returns the options for deriving sitemap values selected
+// by our flags, loading any rules
+func sitemapOptions() (*sitemap.Options, error) {
+	opts := &sitemap.Options{
+		Priority: *priorityFrom,
+		Gzip:     *gzipSitemaps,
+		Images:   *sitemapImages,
+		Videos:   *sitemapVideos,
+	}
+	switch opts.Priority {
+	case sitemap.PriorityDepth, sitemap.PriorityInlinks, sitemap.PriorityPageRank:
+	default:
+		return nil, fmt.Errorf("Unknown priority '%s'", opts.Priority)
+	}
+
+	if *sitemapRules != "" {
+		f, err := os.Open(*sitemapRules)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		opts.Rules, err = sitemap.LoadRules(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return opts, nil
+}
+
+// writeSitemapIndex writes an index referencing the named sitemaps
+// where they will be hosted
+func writeSitemapIndex(outdir string, target *url.URL, names []string) {
+
+	base, err := sitemapBaseUrl(target)
+	if err != nil {
+		log.Criticalf("Could not parse sitemap base url '%s' - %v", *sitemapBase, err)
+		os.Exit(1)
+	}
+
+	locations := make([]*url.URL, len(names))
+	for i, name := range names {
+		locations[i] = base.ResolveReference(&url.URL{Path: name})
+	}
+
+	indexout := fmt.Sprintf("%s/%s-sitemap_index.xml", outdir, target.Host)
+	f, err := os.Create(indexout)
+	if err == nil {
+		err = sitemap.WriteXMLSitemapIndex(f, locations)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		log.Criticalf("Failed to write sitemap index to %s: %v", indexout, err)
+		os.Exit(1)
+	}
+	log.Infof("Wrote XML sitemap index to %s", indexout)
+}
+
+// sitemapBaseUrl is where our sitemaps will be hosted, which defaults to
+// the root of our target. This always ends in a slash, so sitemap file
+// names resolve inside it.
+func sitemapBaseUrl(target *url.URL) (*url.URL, error) {
+	if *sitemapBase == "" {
+		return target.ResolveReference(&url.URL{Path: "/"}), nil
+	}
+
+	base, err := target.Parse(*sitemapBase)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	return base, nil
+}
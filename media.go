@@ -0,0 +1,164 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"code.google.com/p/go.net/html"
+	"code.google.com/p/go.net/html/atom"
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+// extractImages from a document, titled and captioned from their
+// title and alt text, or the caption of the figure they're in
+func (e *extractor) extractImages(doc *goquery.Document) []*domain.Image {
+	images := make([]*domain.Image, 0)
+	seen := make(map[string]bool)
+
+	for _, n := range doc.Find("img").Nodes {
+		uri := e.mediaUrl(doc.Url, attr(n, "src"))
+		if uri == nil || seen[uri.String()] {
+			continue
+		}
+		seen[uri.String()] = true
+
+		caption := collapseSpace(attr(n, "alt"))
+		if caption == "" {
+			caption = figureCaption(n)
+		}
+
+		images = append(images, &domain.Image{
+			Url:     uri,
+			Title:   collapseSpace(attr(n, "title")),
+			Caption: caption,
+		})
+	}
+
+	return images
+}
+
+// extractVideos from a document. Where a video doesn't describe itself
+// we fall back to the caption of the figure it's in, and then to the
+// page's own metadata.
+func (e *extractor) extractVideos(doc *goquery.Document) []*domain.Video {
+	videos := make([]*domain.Video, 0)
+	seen := make(map[string]bool)
+	meta := e.pageMetadata(doc)
+
+	for _, n := range doc.Find("video").Nodes {
+		uri := e.mediaUrl(doc.Url, videoSource(n))
+		if uri == nil || seen[uri.String()] {
+			continue
+		}
+		seen[uri.String()] = true
+
+		v := &domain.Video{
+			Url:         uri,
+			Thumbnail:   e.mediaUrl(doc.Url, firstOf(attr(n, "poster"), meta["og:image"])),
+			Title:       firstOf(attr(n, "title"), attr(n, "aria-label"), meta["og:title"], meta["title"]),
+			Description: firstOf(figureCaption(n), meta["description"], meta["og:description"]),
+		}
+
+		videos = append(videos, v)
+	}
+
+	return videos
+}
+
+// mediaUrl resolves the location of an image or video, ignoring
+// blank locations and inline data which can't be listed in a sitemap
+func (e *extractor) mediaUrl(parent *url.URL, src string) *url.URL {
+	src = strings.TrimSpace(src)
+	if src == "" || strings.HasPrefix(strings.ToLower(src), "data:") {
+		return nil
+	}
+
+	return e.normaliseUrl(parent, src)
+}
+
+// videoSource is the src of a video element, or of the first
+// source element within it
+func videoSource(n *html.Node) string {
+	if src := attr(n, "src"); src != "" {
+		return src
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.DataAtom == atom.Source && attr(c, "src") != "" {
+			return attr(c, "src")
+		}
+	}
+
+	return ""
+}
+
+// pageMetadata collects a page's title, along with the content
+// of its named meta tags and open graph properties
+func (e *extractor) pageMetadata(doc *goquery.Document) map[string]string {
+	ret := make(map[string]string)
+
+	ret["title"] = e.extractTitle(doc)
+
+	for _, n := range doc.Find("meta").Nodes {
+		key := strings.ToLower(firstOf(attr(n, "property"), attr(n, "name")))
+		if key != "" && ret[key] == "" {
+			ret[key] = collapseSpace(attr(n, "content"))
+		}
+	}
+
+	return ret
+}
+
+// figureCaption is the caption of the figure a node is in, if any
+func figureCaption(n *html.Node) string {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.DataAtom != atom.Figure {
+			continue
+		}
+		for c := p.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom == atom.Figcaption {
+				return nodeText(c)
+			}
+		}
+		return ""
+	}
+
+	return ""
+}
+
+// nodeText is all the text within a node, with whitespace collapsed
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return collapseSpace(b.String())
+}
+
+// collapseSpace trims a string and collapses runs of whitespace
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// firstOf returns the first non-blank value
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v = collapseSpace(v); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+	"github.com/mattheath/kraken/sitemap"
+)
+
+const mediaPage = `<html>
+<head>
+	<title>Release the Kraken</title>
+	<meta name="description" content="All about krakens">
+	<meta property="og:image" content="/images/og.jpg">
+</head>
+<body>
+	<img src="/images/kraken.jpg" alt="A kraken" title="Kraken">
+	<img src="/images/kraken.jpg" alt="The same kraken">
+	<img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=">
+	<figure>
+		<img src="/images/ship.png">
+		<figcaption>A ship,
+			shortly before</figcaption>
+	</figure>
+	<figure>
+		<video poster="/images/poster.jpg" title="Kraken attack">
+			<source src="/videos/attack.webm" type="video/webm">
+			<source src="/videos/attack.mp4" type="video/mp4">
+		</video>
+		<figcaption>The kraken attacks</figcaption>
+	</figure>
+	<video src="/videos/sleeping.mp4"></video>
+	<video></video>
+</body>
+</html>`
+
+func TestExtractMedia(t *testing.T) {
+	e := &extractor{}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(mediaPage))
+	assert.Nil(t, err)
+	doc.Url = strToUrl("http://example.com/kraken")
+
+	images := e.extractImages(doc)
+	assert.Len(t, images, 2)
+	assert.Equal(t, "http://example.com/images/kraken.jpg", images[0].Url.String())
+	assert.Equal(t, "Kraken", images[0].Title)
+	assert.Equal(t, "A kraken", images[0].Caption)
+
+	// Falling back to the figure's caption
+	assert.Equal(t, "http://example.com/images/ship.png", images[1].Url.String())
+	assert.Equal(t, "A ship, shortly before", images[1].Caption)
+
+	videos := e.extractVideos(doc)
+	assert.Len(t, videos, 2)
+	assert.Equal(t, "http://example.com/videos/attack.webm", videos[0].Url.String())
+	assert.Equal(t, "http://example.com/images/poster.jpg", videos[0].Thumbnail.String())
+	assert.Equal(t, "Kraken attack", videos[0].Title)
+	assert.Equal(t, "The kraken attacks", videos[0].Description)
+
+	// Falling back to the page's metadata
+	assert.Equal(t, "http://example.com/videos/sleeping.mp4", videos[1].Url.String())
+	assert.Equal(t, "http://example.com/images/og.jpg", videos[1].Thumbnail.String())
+	assert.Equal(t, "Release the Kraken", videos[1].Title)
+	assert.Equal(t, "All about krakens", videos[1].Description)
+}
+
+func TestExtractVideoWithoutDescription(t *testing.T) {
+	e := &extractor{}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body>
+		<video src="/videos/attack.mp4" title="Kraken attack" poster="/poster.jpg"></video>
+	</body></html>`))
+	assert.Nil(t, err)
+	doc.Url = strToUrl("http://example.com/kraken")
+
+	// The title isn't repeated as a description
+	videos := e.extractVideos(doc)
+	assert.Len(t, videos, 1)
+	assert.Equal(t, "Kraken attack", videos[0].Title)
+	assert.Equal(t, "", videos[0].Description)
+
+	// So the video is left out of video sitemaps
+	page := &domain.Page{Url: doc.Url, Videos: videos}
+	b, err := sitemap.BuildXMLSitemap([]*domain.Page{page}, &sitemap.Options{Videos: true})
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), "<video:video>")
+}
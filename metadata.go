@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// lastModifiedMeta are meta tag names and properties which
+// may hold when a page was last modified
+var lastModifiedMeta = []string{
+	"article:modified_time",
+	"og:updated_time",
+	"dateModified",
+	"last-modified",
+	"revised",
+}
+
+// metaTimeFormats we accept in page metadata
+var metaTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	time.RFC1123,
+	time.RFC1123Z,
+}
+
+// extractLastModified finds when a page was last modified. Page metadata
+// is preferred, as servers often send the time the response was generated
+// in their Last-Modified header.
+func (e *extractor) extractLastModified(doc *goquery.Document, header http.Header) time.Time {
+
+	for _, n := range doc.Find("meta").Nodes {
+		for _, key := range []string{"property", "name", "itemprop", "http-equiv"} {
+			if !isLastModifiedMeta(attr(n, key)) {
+				continue
+			}
+			if t, ok := parseMetaTime(attr(n, "content")); ok {
+				return t
+			}
+		}
+	}
+
+	if t, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		return t
+	}
+
+	return time.Time{}
+}
+
+// extractTitle returns the title of a document, if it has one
+func (e *extractor) extractTitle(doc *goquery.Document) string {
+	for _, n := range doc.Find("title").Nodes {
+		return nodeText(n)
+	}
+
+	return ""
+}
+
+// isLastModifiedMeta determines if a meta tag name holds the last modified time
+func isLastModifiedMeta(name string) bool {
+	for _, m := range lastModifiedMeta {
+		if strings.EqualFold(name, m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseMetaTime parses a time in any of the formats commonly used in metadata
+func parseMetaTime(v string) (time.Time, bool) {
+	v = strings.TrimSpace(v)
+	for _, f := range metaTimeFormats {
+		if t, err := time.Parse(f, v); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractLastModified(t *testing.T) {
+	e := &extractor{}
+	header := http.Header{}
+	header.Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+
+	testCases := map[string]time.Time{
+		// Metadata is preferred to the header
+		`<meta property="article:modified_time" content="2014-06-01T12:00:00+01:00">`: time.Date(2014, 6, 1, 11, 0, 0, 0, time.UTC),
+		`<meta itemprop="dateModified" content="2014-06-02">`:                         time.Date(2014, 6, 2, 0, 0, 0, 0, time.UTC),
+		`<meta http-equiv="Last-Modified" content="2014-06-03T09:30">`:                time.Date(2014, 6, 3, 9, 30, 0, 0, time.UTC),
+
+		// Falling back to the header if metadata is missing or invalid
+		`<meta name="revised" content="last tuesday">`: time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC),
+		`<p>No metadata</p>`:                           time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC),
+	}
+
+	for html, expected := range testCases {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		assert.Nil(t, err)
+		assert.True(t, expected.Equal(e.extractLastModified(doc, header)), html)
+	}
+
+	// Unknown without either
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(`<p>Nothing</p>`))
+	assert.True(t, e.extractLastModified(doc, http.Header{}).IsZero())
+}
+
+func TestExtractTitle(t *testing.T) {
+	e := &extractor{}
+
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader("<title>\n\tRelease the\n\tKraken </title>"))
+	assert.Equal(t, "Release the Kraken", e.extractTitle(doc))
+
+	doc, _ = goquery.NewDocumentFromReader(strings.NewReader(`<p>Untitled</p>`))
+	assert.Equal(t, "", e.extractTitle(doc))
+}
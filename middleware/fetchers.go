@@ -0,0 +1,251 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/mattheath/kraken/crawler"
+	"github.com/mattheath/kraken/domain"
+)
+
+var (
+	InjectedFault = errors.New("Fault injected by middleware")
+)
+
+// Logging logs each fetch and its outcome
+func Logging() Middleware {
+	return func(next crawler.Fetcher) crawler.Fetcher {
+		return FetcherFunc(func(target *url.URL) (*domain.Page, error) {
+			log.Debugf("Fetching %s", target)
+
+			page, err := next.Fetch(target)
+			if err != nil {
+				log.Debugf("Fetching %s failed: %v", target, err)
+			} else {
+				log.Debugf("Fetched %s, %v links and %v assets", target, len(page.Links), len(page.Assets))
+			}
+
+			return page, err
+		})
+	}
+}
+
+// Timings collects how long fetches take, and is safe
+// to use from multiple goroutines
+type Timings struct {
+	sync.Mutex
+
+	Count int
+	Total time.Duration
+	Max   time.Duration
+}
+
+// Mean duration of the fetches timed so far
+func (t *Timings) Mean() time.Duration {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.Count == 0 {
+		return 0
+	}
+	return t.Total / time.Duration(t.Count)
+}
+
+// Timing records the duration of each fetch into timings
+func Timing(timings *Timings) Middleware {
+	return func(next crawler.Fetcher) crawler.Fetcher {
+		return FetcherFunc(func(target *url.URL) (*domain.Page, error) {
+			start := time.Now()
+			page, err := next.Fetch(target)
+			d := time.Since(start)
+
+			timings.Lock()
+			timings.Count++
+			timings.Total += d
+			if d > timings.Max {
+				timings.Max = d
+			}
+			timings.Unlock()
+
+			return page, err
+		})
+	}
+}
+
+// Cache stores successfully fetched pages in memory, so each URL is only
+// fetched once however many times it is requested. Copies of cached pages
+// are returned, as the crawler modifies the pages it is given.
+func Cache() Middleware {
+	return func(next crawler.Fetcher) crawler.Fetcher {
+		var mtx sync.Mutex
+		pages := make(map[string]*domain.Page)
+
+		return FetcherFunc(func(target *url.URL) (*domain.Page, error) {
+			mtx.Lock()
+			cached, ok := pages[target.String()]
+			mtx.Unlock()
+			if ok {
+				return copyPage(cached), nil
+			}
+
+			page, err := next.Fetch(target)
+			if err != nil {
+				return nil, err
+			}
+
+			mtx.Lock()
+			pages[target.String()] = copyPage(page)
+			mtx.Unlock()
+
+			return page, nil
+		})
+	}
+}
+
+// Retry reattempts fetches which failed transiently up to the specified
+// number of times, doubling the delay between each attempt. Fetches are
+// retried after network errors such as timeouts, or responses which
+// may succeed later, such as 503 Service Unavailable. Permanent errors,
+// eg. pages disallowed by robots.txt or redirect loops, are returned
+// immediately.
+func Retry(retries int, backoff time.Duration) Middleware {
+	return func(next crawler.Fetcher) crawler.Fetcher {
+		return FetcherFunc(func(target *url.URL) (*domain.Page, error) {
+			delay := backoff
+			page, err := next.Fetch(target)
+			for i := 0; retryable(page, err) && i < retries; i++ {
+				if err != nil {
+					log.Debugf("Retrying %s in %v after error: %v", target, delay, err)
+				} else {
+					log.Debugf("Retrying %s in %v after status %v", target, delay, page.Status)
+				}
+				time.Sleep(delay)
+				delay *= 2
+
+				page, err = next.Fetch(target)
+			}
+
+			return page, err
+		})
+	}
+}
+
+// retryStatuses are responses which may succeed if retried, as the
+// server was overloaded or briefly unavailable
+var retryStatuses = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// retryable determines if a fetch failed transiently, so may succeed
+// if retried
+func retryable(page *domain.Page, err error) bool {
+	if err == nil {
+		return page != nil && retryStatuses[page.Status]
+	}
+
+	// Connections which failed or were cut short, and injected faults
+	// which stand in for them
+	for _, e := range []error{InjectedFault, io.EOF, io.ErrUnexpectedEOF, syscall.ECONNREFUSED, syscall.ECONNRESET} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+
+	// DNS lookups which failed outright, rather than timed out, won't
+	// succeed on retrying, unlike other network timeouts
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// RateLimit spaces out fetches so no more than perSecond are started
+// each second, across all goroutines
+func RateLimit(perSecond float64) Middleware {
+	interval := time.Duration(float64(time.Second) / perSecond)
+
+	return func(next crawler.Fetcher) crawler.Fetcher {
+		var mtx sync.Mutex
+		var last time.Time
+
+		return FetcherFunc(func(target *url.URL) (*domain.Page, error) {
+
+			// Reserve the next free slot, then wait for it outside the lock
+			mtx.Lock()
+			slot := last.Add(interval)
+			if now := time.Now(); slot.Before(now) {
+				slot = now
+			}
+			last = slot
+			mtx.Unlock()
+
+			time.Sleep(time.Until(slot))
+
+			return next.Fetch(target)
+		})
+	}
+}
+
+// FaultInjection fails the specified proportion of fetches, between 0 and 1,
+// with InjectedFault, so the crawler's handling of errors can be exercised
+func FaultInjection(rate float64, seed int64) Middleware {
+	return func(next crawler.Fetcher) crawler.Fetcher {
+		var mtx sync.Mutex
+		rnd := rand.New(rand.NewSource(seed))
+
+		return FetcherFunc(func(target *url.URL) (*domain.Page, error) {
+			mtx.Lock()
+			fail := rnd.Float64() < rate
+			mtx.Unlock()
+
+			if fail {
+				log.Debugf("Injecting fault into fetch of %s", target)
+				return nil, InjectedFault
+			}
+
+			return next.Fetch(target)
+		})
+	}
+}
+
+// Robots refuses to fetch URLs which aren't allowed, returning
+// crawler.RobotsDisallowed so the crawler can tell they were blocked
+func Robots(allowed func(target *url.URL) bool) Middleware {
+	return func(next crawler.Fetcher) crawler.Fetcher {
+		return FetcherFunc(func(target *url.URL) (*domain.Page, error) {
+			if !allowed(target) {
+				log.Debugf("Not fetching %s as disallowed by robots.txt", target)
+				return nil, crawler.RobotsDisallowed
+			}
+
+			return next.Fetch(target)
+		})
+	}
+}
+
+// copyPage copies a page and its links, which the crawler modifies
+func copyPage(p *domain.Page) *domain.Page {
+	cp := *p
+
+	cp.Links = make([]*domain.Link, len(p.Links))
+	for i, l := range p.Links {
+		lcp := *l
+		cp.Links[i] = &lcp
+	}
+
+	return &cp
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/url"
+
+	"github.com/mattheath/kraken/crawler"
+	"github.com/mattheath/kraken/domain"
+)
+
+// Middleware wraps a Fetcher, adding behaviour around each fetch
+type Middleware func(crawler.Fetcher) crawler.Fetcher
+
+// FetcherFunc allows a plain function to be used as a Fetcher
+type FetcherFunc func(target *url.URL) (*domain.Page, error)
+
+// Fetch calls f(target)
+func (f FetcherFunc) Fetch(target *url.URL) (*domain.Page, error) {
+	return f(target)
+}
+
+// Chain wraps a Fetcher in a stack of middleware. The first middleware
+// is outermost, so sees each fetch first and its result last.
+func Chain(f crawler.Fetcher, mw ...Middleware) crawler.Fetcher {
+	for i := len(mw) - 1; i >= 0; i-- {
+		f = mw[i](f)
+	}
+
+	return f
+}
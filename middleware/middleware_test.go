@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/crawler"
+	"github.com/mattheath/kraken/domain"
+)
+
+// timeout is a transient network error
+var timeout = &net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}
+
+// countingFetcher returns a page for every URL, failing the first
+// failures fetches, and counts how many fetches it has seen
+type countingFetcher struct {
+	sync.Mutex
+	fetches  int
+	failures int
+
+	// Failed fetches respond with status if set, or return err,
+	// or a timeout if neither are
+	status int
+	err    error
+}
+
+func (f *countingFetcher) Fetch(target *url.URL) (*domain.Page, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.fetches++
+	if f.fetches <= f.failures {
+		switch {
+		case f.status != 0:
+			return &domain.Page{Url: target, Status: f.status}, nil
+		case f.err != nil:
+			return nil, f.err
+		}
+		return nil, timeout
+	}
+
+	return &domain.Page{
+		Url: target,
+		Links: []*domain.Link{
+			&domain.Link{Target: target},
+		},
+	}, nil
+}
+
+func TestChainOrder(t *testing.T) {
+	order := make([]string, 0)
+	named := func(name string) Middleware {
+		return func(next crawler.Fetcher) crawler.Fetcher {
+			return FetcherFunc(func(target *url.URL) (*domain.Page, error) {
+				order = append(order, name)
+				return next.Fetch(target)
+			})
+		}
+	}
+
+	f := Chain(&countingFetcher{}, named("outer"), named("middle"), named("inner"))
+	f.Fetch(strToUrl("http://example.com/"))
+
+	assert.Equal(t, []string{"outer", "middle", "inner"}, order)
+}
+
+func TestCache(t *testing.T) {
+	base := &countingFetcher{failures: 1}
+	f := Chain(base, Cache())
+
+	// Errors are not cached
+	_, err := f.Fetch(strToUrl("http://example.com/"))
+	assert.NotNil(t, err)
+
+	for i := 0; i < 3; i++ {
+		page, err := f.Fetch(strToUrl("http://example.com/"))
+		assert.Nil(t, err)
+
+		// Modifying a page shouldn't change what's cached
+		page.Links[0].Source = strToUrl("http://example.com/modified")
+	}
+
+	page, _ := f.Fetch(strToUrl("http://example.com/"))
+	assert.Nil(t, page.Links[0].Source)
+	assert.Equal(t, 2, base.fetches)
+}
+
+func TestRetry(t *testing.T) {
+	base := &countingFetcher{failures: 2}
+	f := Chain(base, Retry(2, time.Millisecond))
+
+	_, err := f.Fetch(strToUrl("http://example.com/"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, base.fetches)
+
+	// Give up once out of retries
+	base = &countingFetcher{failures: 3}
+	f = Chain(base, Retry(1, time.Millisecond))
+
+	_, err = f.Fetch(strToUrl("http://example.com/"))
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, base.fetches)
+}
+
+func TestRetryTransientStatus(t *testing.T) {
+	for _, status := range []int{429, 502, 503, 504} {
+		base := &countingFetcher{failures: 1, status: status}
+		f := Chain(base, Retry(2, time.Millisecond))
+
+		page, err := f.Fetch(strToUrl("http://example.com/"))
+		assert.Nil(t, err)
+		assert.Equal(t, 0, page.Status)
+		assert.Equal(t, 2, base.fetches, "%v", status)
+	}
+
+	// Other statuses won't change by retrying
+	for _, status := range []int{404, 500} {
+		base := &countingFetcher{failures: 1, status: status}
+		f := Chain(base, Retry(2, time.Millisecond))
+
+		page, _ := f.Fetch(strToUrl("http://example.com/"))
+		assert.Equal(t, status, page.Status)
+		assert.Equal(t, 1, base.fetches, "%v", status)
+	}
+}
+
+func TestRetryPermanentErrors(t *testing.T) {
+	testCases := []error{
+		crawler.RobotsDisallowed,
+		crawler.LogoutSkipped,
+		&url.Error{Op: "Get", URL: "http://example.com/", Err: crawler.RedirectLoop},
+		&net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true},
+		errors.New("Not archived"),
+	}
+
+	for _, tc := range testCases {
+		base := &countingFetcher{failures: 3, err: tc}
+		f := Chain(base, Retry(2, time.Millisecond))
+
+		_, err := f.Fetch(strToUrl("http://example.com/"))
+		assert.Equal(t, tc, err)
+		assert.Equal(t, 1, base.fetches, "%v", tc)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	f := Chain(&countingFetcher{}, RateLimit(100))
+
+	// Five fetches should be spaced over at least 40ms
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.Fetch(strToUrl("http://example.com/"))
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+}
+
+func TestFaultInjectionAndTiming(t *testing.T) {
+	timings := &Timings{}
+
+	f := Chain(&countingFetcher{}, Timing(timings), FaultInjection(1, 1))
+	_, err := f.Fetch(strToUrl("http://example.com/"))
+	assert.Equal(t, InjectedFault, err)
+
+	f = Chain(&countingFetcher{}, Timing(timings), FaultInjection(0, 1))
+	_, err = f.Fetch(strToUrl("http://example.com/"))
+	assert.Nil(t, err)
+
+	// Fetches are timed whether or not they fail
+	assert.Equal(t, 2, timings.Count)
+}
+
+func TestRobots(t *testing.T) {
+	base := &countingFetcher{}
+	f := Chain(base, Robots(func(target *url.URL) bool {
+		return target.Path != "/private"
+	}))
+
+	_, err := f.Fetch(strToUrl("http://example.com/private"))
+	assert.Equal(t, crawler.RobotsDisallowed, err)
+
+	_, err = f.Fetch(strToUrl("http://example.com/public"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, base.fetches)
+}
+
+func strToUrl(s string) *url.URL {
+	u, _ := url.Parse(s)
+	return u
+}
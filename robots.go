@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/url"
+	"strings"
+
+	log "github.com/cihub/seelog"
+)
+
+// robotsAgent is the user agent we look for in robots.txt
+const robotsAgent = "kraken"
+
+// robotsRules are the paths a robots.txt file allows and disallows us,
+// along with the sitemaps it declares
+type robotsRules struct {
+	allow    []string
+	disallow []string
+	sitemaps []string
+}
+
+// parseRobots reads the rules in a robots.txt file for our user agent,
+// falling back to the rules for all agents if none name us. Sitemaps
+// are declared for every agent.
+func parseRobots(r io.Reader) (*robotsRules, error) {
+	ours, all := &robotsRules{}, &robotsRules{}
+	named := false
+	sitemaps := make([]string, 0)
+
+	// Groups start with one or more user agent lines
+	var current []*robotsRules
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		val := strings.TrimSpace(line[i+1:])
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = nil
+				inAgents = true
+			}
+			agent := strings.ToLower(val)
+			switch {
+			case strings.Contains(agent, robotsAgent):
+				current = append(current, ours)
+				named = true
+			case agent == "*":
+				current = append(current, all)
+			}
+
+		case "allow", "disallow":
+			inAgents = false
+			if val == "" {
+				continue
+			}
+			for _, rules := range current {
+				if key == "allow" {
+					rules.allow = append(rules.allow, val)
+				} else {
+					rules.disallow = append(rules.disallow, val)
+				}
+			}
+
+		// Sitemaps stand apart from groups, so don't end them
+		case "sitemap":
+			if val != "" {
+				sitemaps = append(sitemaps, val)
+			}
+
+		default:
+			inAgents = false
+		}
+	}
+
+	if named {
+		ours.sitemaps = sitemaps
+		return ours, scanner.Err()
+	}
+	all.sitemaps = sitemaps
+	return all, scanner.Err()
+}
+
+// allowed determines if we may crawl a URL. The longest matching rule
+// applies, and allow wins if rules are the same length.
+func (r *robotsRules) allowed(u *url.URL) bool {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	longest := func(patterns []string) int {
+		ret := -1
+		for _, p := range patterns {
+			if len(p) > ret && robotsMatch(p, path) {
+				ret = len(p)
+			}
+		}
+		return ret
+	}
+
+	return longest(r.allow) >= longest(r.disallow)
+}
+
+// robotsMatch matches a path against a robots.txt pattern, which matches
+// path prefixes and may include * wildcards, or end with $ to match the
+// end of the path
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	return wildcardMatch(strings.TrimSuffix(pattern, "$"), path, anchored)
+}
+
+// wildcardMatch matches a prefix of s, or all of s if anchored,
+// against a pattern including * wildcards
+func wildcardMatch(pattern, s string, anchored bool) bool {
+	i := strings.Index(pattern, "*")
+	if i < 0 {
+		if anchored {
+			return s == pattern
+		}
+		return strings.HasPrefix(s, pattern)
+	}
+
+	if !strings.HasPrefix(s, pattern[:i]) {
+		return false
+	}
+
+	// Try the wildcard against every possible length
+	for j := i; j <= len(s); j++ {
+		if wildcardMatch(pattern[i+1:], s[j:], anchored) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retrieveRobots fetches and parses our target's robots.txt, once for
+// both the rules we obey and the sitemaps we read. If there isn't one,
+// everything is allowed and no sitemaps are declared.
+func retrieveRobots(r Retriever, target *url.URL) *robotsRules {
+	robots := target.ResolveReference(&url.URL{Path: "/robots.txt"})
+
+	body, err := r.Retrieve(robots)
+	if err != nil {
+		log.Debugf("Failed to retrieve %s: %v", robots, err)
+		return &robotsRules{}
+	}
+	defer body.Close()
+
+	rules, err := parseRobots(body)
+	if err != nil {
+		log.Warnf("Failed to parse %s: %v", robots, err)
+	}
+
+	return rules
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const robotsTxt = `# Rules for everyone
+User-agent: *
+Disallow: /private/
+Disallow: /*.pdf$
+Allow: /private/public/
+
+# Rules just for us
+User-agent: Googlebot
+User-agent: Kraken
+Disallow: /admin
+Disallow: /search?
+
+Sitemap: http://example.com/sitemap.xml
+`
+
+func TestParseRobots(t *testing.T) {
+	rules, err := parseRobots(strings.NewReader(robotsTxt))
+	assert.Nil(t, err)
+
+	// Our own group replaces the rules for everyone
+	testCases := map[string]bool{
+		"http://example.com/":             true,
+		"http://example.com/admin":        false,
+		"http://example.com/admin/users":  false,
+		"http://example.com/search":       true,
+		"http://example.com/search?q=1":   false,
+		"http://example.com/private/":     true,
+		"http://example.com/docs/doc.pdf": true,
+	}
+	for u, expected := range testCases {
+		assert.Equal(t, expected, rules.allowed(strToUrl(u)), u)
+	}
+
+	rules, err = parseRobots(strings.NewReader(strings.Replace(robotsTxt, "Kraken", "Bingbot", 1)))
+	assert.Nil(t, err)
+
+	testCases = map[string]bool{
+		"http://example.com/admin":              true,
+		"http://example.com/private/":           false,
+		"http://example.com/private/public/doc": true,
+		"http://example.com/docs/doc.pdf":       false,
+		"http://example.com/docs/doc.pdf?v=2":   true,
+	}
+	for u, expected := range testCases {
+		assert.Equal(t, expected, rules.allowed(strToUrl(u)), u)
+	}
+}
+
+func TestParseRobotsSitemaps(t *testing.T) {
+	robots := `User-agent: *
+Disallow: /private # keep out
+sitemap: http://example.com/sitemap-a.xml
+Sitemap:http://example.com/sitemap-b.xml.gz
+# Sitemap: http://example.com/commented.xml
+Sitemap:
+User-agent: Kraken
+Disallow: /admin
+`
+
+	rules, err := parseRobots(strings.NewReader(robots))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"http://example.com/sitemap-a.xml", "http://example.com/sitemap-b.xml.gz"}, rules.sitemaps)
+	assert.False(t, rules.allowed(strToUrl("http://example.com/admin")))
+
+	rules, err = parseRobots(strings.NewReader(robotsTxt))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"http://example.com/sitemap.xml"}, rules.sitemaps)
+}
+
+func TestRobotsMatch(t *testing.T) {
+	testCases := []struct {
+		pattern, path string
+		expected      bool
+	}{
+		{"/", "/anything", true},
+		{"/fish", "/fish.html", true},
+		{"/fish", "/Fish", false},
+		{"/fish*.php", "/fish/salmon.php", true},
+		{"/*.php$", "/index.php", true},
+		{"/*.php$", "/index.php?a=1", false},
+		{"/*.php$", "/a.php/b.php", true},
+		{"/fish$", "/fish/", false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, robotsMatch(tc.pattern, tc.path), tc.pattern+" "+tc.path)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/url"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/mattheath/kraken/sitemap"
+)
+
+// maxSitemaps bounds how many sitemaps we'll read, in case
+// sitemap indexes refer to each other in a loop
+const maxSitemaps = 1000
+
+// discoverSitemapUrls finds every URL listed in the sitemaps declared
+// in our target's robots.txt, or at /sitemap.xml, following sitemap
+// indexes. Sitemaps which can't be retrieved are logged and skipped.
+func discoverSitemapUrls(r Retriever, target *url.URL, robots *robotsRules) []*url.URL {
+
+	// Sitemaps declared in robots.txt take priority
+	queue := append([]string{}, robots.sitemaps...)
+	queue = append(queue, target.ResolveReference(&url.URL{Path: "/sitemap.xml"}).String())
+
+	seen := make(map[string]bool)
+	urls := make([]*url.URL, 0)
+	for len(queue) > 0 && len(seen) < maxSitemaps {
+		loc := queue[0]
+		queue = queue[1:]
+
+		u, err := target.Parse(loc)
+		if err != nil || seen[u.String()] {
+			continue
+		}
+		seen[u.String()] = true
+
+		body, err := r.Retrieve(u)
+		if err != nil {
+			log.Debugf("Failed to retrieve sitemap %s: %v", u, err)
+			continue
+		}
+
+		listed, sitemaps, err := sitemap.ParseXMLSitemap(body)
+		body.Close()
+		if err != nil {
+			log.Warnf("Failed to parse sitemap %s: %v", u, err)
+			continue
+		}
+		log.Debugf("Found %v URLs and %v sitemaps in %s", len(listed), len(sitemaps), u)
+
+		for _, l := range listed {
+			if lu, err := u.Parse(l); err == nil {
+				urls = append(urls, lu)
+			}
+		}
+		queue = append(queue, sitemaps...)
+	}
+
+	return urls
+}
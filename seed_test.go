@@ -0,0 +1,47 @@
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscoverSitemapUrls(t *testing.T) {
+	root := writeFakeSite(t)
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"robots.txt": "User-agent: *\nSitemap: http://example.com/sitemaps/index.xml\n",
+		"sitemaps/index.xml": `<sitemapindex>
+			<sitemap><loc>/sitemaps/pages.xml.gz</loc></sitemap>
+			<sitemap><loc>/sitemaps/index.xml</loc></sitemap>
+			<sitemap><loc>/sitemaps/missing.xml</loc></sitemap>
+		</sitemapindex>`,
+		"sitemap.xml": `<urlset><url><loc>http://example.com/about</loc></url></urlset>`,
+	}
+	for name, content := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(filename), 0755)
+		f, _ := os.Create(filename)
+		f.WriteString(content)
+		f.Close()
+	}
+
+	// Gzipped sitemaps listed in the index should be read too
+	f, _ := os.Create(filepath.Join(root, "sitemaps", "pages.xml.gz"))
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(`<urlset><url><loc>http://example.com/docs/api/v1.html</loc></url></urlset>`))
+	gz.Close()
+	f.Close()
+
+	fetcher := &FileFetcher{Root: root, Host: "example.com"}
+	target := strToUrl("http://example.com/")
+	urls := urlsToStrings(discoverSitemapUrls(fetcher, target, retrieveRobots(fetcher, target)))
+	sort.Strings(urls)
+
+	assert.Equal(t, []string{"http://example.com/about", "http://example.com/docs/api/v1.html"}, urls)
+}
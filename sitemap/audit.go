@@ -0,0 +1,122 @@
+package sitemap
+
+import (
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+// Audit compares the URLs listed in a site's existing sitemaps with
+// the pages found by crawling it
+type Audit struct {
+	// Unreached URLs are listed, but weren't reached by the crawl
+	Unreached []string `json:"unreached"`
+
+	// Unlisted pages were crawled, but aren't listed
+	Unlisted []string `json:"unlisted"`
+
+	// NotOk URLs are listed, but don't respond OK
+	NotOk []*AuditStatus `json:"not_ok"`
+}
+
+// AuditStatus is the response to a listed URL which didn't respond OK
+type AuditStatus struct {
+	Url    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// AuditSitemap compares the listed URLs with every page discovered by a
+// crawl. Listed URLs which the crawl didn't reach are checked with
+// status, which returns the status code they first respond with. Listed
+// URLs which redirect aren't OK, as sitemaps should list where they lead.
+func AuditSitemap(listed []string, pages []*domain.Page, status func(u string) (int, error)) *Audit {
+	a := &Audit{
+		Unreached: make([]string, 0),
+		Unlisted:  make([]string, 0),
+		NotOk:     make([]*AuditStatus, 0),
+	}
+
+	isListed := make(map[string]bool)
+	for _, u := range listed {
+		isListed[u] = true
+	}
+
+	// Pages the crawl reached, whether or not they could be fetched
+	reached := make(map[string]*domain.Page)
+	for _, p := range pages {
+		if p == nil || p.Url == nil {
+			continue
+		}
+
+		switch p.Outcome {
+		case "", domain.OutcomeOk:
+			if firstStatus(p) == http.StatusOK && !isListed[p.Url.String()] {
+				a.Unlisted = append(a.Unlisted, p.Url.String())
+			}
+			fallthrough
+		case domain.OutcomeError:
+			reached[p.Url.String()] = p
+		}
+	}
+
+	for u := range isListed {
+		p, ok := reached[u]
+		switch {
+		case !ok:
+			a.Unreached = append(a.Unreached, u)
+			code, err := status(u)
+			if err != nil {
+				a.NotOk = append(a.NotOk, &AuditStatus{Url: u, Error: err.Error()})
+			} else if code != http.StatusOK {
+				a.NotOk = append(a.NotOk, &AuditStatus{Url: u, Status: code})
+			}
+		case p.Outcome == domain.OutcomeError:
+			a.NotOk = append(a.NotOk, &AuditStatus{Url: u, Error: p.Error})
+		case firstStatus(p) != http.StatusOK:
+			a.NotOk = append(a.NotOk, &AuditStatus{Url: u, Status: firstStatus(p)})
+		}
+	}
+
+	sort.Strings(a.Unreached)
+	sort.Strings(a.Unlisted)
+	sort.Slice(a.NotOk, func(i, j int) bool {
+		return a.NotOk[i].Url < a.NotOk[j].Url
+	})
+
+	return a
+}
+
+// firstStatus is the status a page first responded with, before
+// following any redirects
+func firstStatus(p *domain.Page) int {
+	if p.RedirectStatus != 0 {
+		return p.RedirectStatus
+	}
+
+	return p.Status
+}
+
+// WriteText writes a human readable report of the audit
+func (a *Audit) WriteText(w io.Writer) error {
+	ew := &errWriter{w: w}
+
+	ew.printf("%v listed URLs not reached by the crawl:\n", len(a.Unreached))
+	for _, u := range a.Unreached {
+		ew.printf("\t%s\n", u)
+	}
+
+	ew.printf("\n%v crawled pages not listed:\n", len(a.Unlisted))
+	for _, u := range a.Unlisted {
+		ew.printf("\t%s\n", u)
+	}
+
+	ew.printf("\n%v listed URLs not responding OK:\n", len(a.NotOk))
+	for _, s := range a.NotOk {
+		ew.printf("\t%s\t%s\n", s.Url, statusText(s.Status, s.Error))
+	}
+
+	return ew.err
+}
@@ -0,0 +1,85 @@
+package sitemap
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+func TestAuditSitemap(t *testing.T) {
+	page := func(path string, status int, outcome string) *domain.Page {
+		return &domain.Page{
+			Url:     &url.URL{Scheme: "http", Host: "example.com", Path: path},
+			Status:  status,
+			Outcome: outcome,
+		}
+	}
+
+	pages := []*domain.Page{
+		page("/", 200, domain.OutcomeOk),
+		page("/about", 200, domain.OutcomeOk),
+		page("/new", 200, domain.OutcomeOk),
+		page("/moved", 404, domain.OutcomeOk),
+		page("/broken", 0, domain.OutcomeError),
+		page("/deep", 0, domain.OutcomeSkipped),
+		page("/redirected", 200, domain.OutcomeOk),
+	}
+	pages[4].Error = "connection refused"
+	pages[6].RedirectStatus = 301
+
+	listed := []string{
+		"http://example.com/",
+		"http://example.com/about",
+		"http://example.com/moved",
+		"http://example.com/broken",
+		"http://example.com/deep",
+		"http://example.com/orphan",
+		"http://example.com/gone",
+		"http://example.com/redirected",
+		"http://example.com/temporary",
+	}
+
+	checked := make([]string, 0)
+	a := AuditSitemap(listed, pages, func(u string) (int, error) {
+		checked = append(checked, u)
+		switch u {
+		case "http://example.com/gone":
+			return 0, errors.New("timeout")
+		case "http://example.com/deep":
+			return 500, nil
+		case "http://example.com/temporary":
+			return 302, nil
+		}
+		return 200, nil
+	})
+
+	assert.Equal(t, []string{
+		"http://example.com/deep",
+		"http://example.com/gone",
+		"http://example.com/orphan",
+		"http://example.com/temporary",
+	}, a.Unreached)
+	assert.ElementsMatch(t, a.Unreached, checked)
+
+	assert.Equal(t, []string{"http://example.com/new"}, a.Unlisted)
+
+	assert.Equal(t, []*AuditStatus{
+		{Url: "http://example.com/broken", Error: "connection refused"},
+		{Url: "http://example.com/deep", Status: 500},
+		{Url: "http://example.com/gone", Error: "timeout"},
+		{Url: "http://example.com/moved", Status: 404},
+		{Url: "http://example.com/redirected", Status: 301},
+		{Url: "http://example.com/temporary", Status: 302},
+	}, a.NotOk)
+
+	var buf bytes.Buffer
+	assert.NoError(t, a.WriteText(&buf))
+	assert.Contains(t, buf.String(), "4 listed URLs not reached by the crawl:\n")
+	assert.Contains(t, buf.String(), "1 crawled pages not listed:\n\thttp://example.com/new\n")
+	assert.Contains(t, buf.String(), "\thttp://example.com/moved\t404\n")
+}
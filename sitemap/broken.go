@@ -0,0 +1,96 @@
+package sitemap
+
+import (
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+// BrokenTarget is a page which failed to be fetched, eg. on a DNS,
+// TLS or timeout error, or which responded with a 4xx or 5xx status,
+// along with every link to it
+type BrokenTarget struct {
+	Url    string      `json:"url"`
+	Status int         `json:"status,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	Links  []*Referrer `json:"links"`
+}
+
+// Referrer is a link to a broken target from another page
+type Referrer struct {
+	Source string `json:"source"`
+	Type   string `json:"type"`
+	Text   string `json:"text,omitempty"`
+}
+
+// FindBrokenTargets finds every crawled page which is broken, and the
+// links to each from other pages, sorted by URL. Pages outside the
+// crawl weren't fetched, so can't be found to be broken.
+func FindBrokenTargets(pages []*domain.Page) []*BrokenTarget {
+	ret := make([]*BrokenTarget, 0)
+	index := make(map[string]*BrokenTarget)
+
+	for _, p := range pages {
+		if p == nil || p.Url == nil || index[p.Url.String()] != nil {
+			continue
+		}
+		if p.Outcome != domain.OutcomeError && p.Status < http.StatusBadRequest {
+			continue
+		}
+
+		b := &BrokenTarget{
+			Url:    p.Url.String(),
+			Status: p.Status,
+			Error:  p.Error,
+			Links:  make([]*Referrer, 0),
+		}
+		index[b.Url] = b
+		ret = append(ret, b)
+	}
+
+	for _, p := range pages {
+		if p == nil || p.Url == nil {
+			continue
+		}
+		for _, l := range p.Links {
+			b, ok := index[l.Target.String()]
+			if !ok || l.Target.String() == p.Url.String() {
+				continue
+			}
+			b.Links = append(b.Links, &Referrer{
+				Source: p.Url.String(),
+				Type:   l.Type,
+				Text:   l.Text,
+			})
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Url < ret[j].Url
+	})
+	for _, b := range ret {
+		sort.SliceStable(b.Links, func(i, j int) bool {
+			return b.Links[i].Source < b.Links[j].Source
+		})
+	}
+
+	return ret
+}
+
+// WriteBrokenTargets writes a human readable report of broken
+// targets, listing the pages linking to each
+func WriteBrokenTargets(w io.Writer, targets []*BrokenTarget) error {
+	ew := &errWriter{w: w}
+
+	ew.printf("%v broken pages:\n", len(targets))
+	for _, b := range targets {
+		ew.printf("\n%s\t%s\n", b.Url, statusText(b.Status, b.Error))
+		for _, l := range b.Links {
+			ew.printf("\t%s\t%s\t%q\n", l.Source, l.Type, l.Text)
+		}
+	}
+
+	return ew.err
+}
@@ -0,0 +1,71 @@
+package sitemap
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+func TestFindBrokenTargets(t *testing.T) {
+	u := func(path string) *url.URL {
+		return &url.URL{Scheme: "http", Host: "example.com", Path: path}
+	}
+	link := func(source, target, text string) *domain.Link {
+		return &domain.Link{Source: u(source), Target: u(target), Type: domain.LinkAnchor, Text: text}
+	}
+
+	pages := []*domain.Page{
+		{
+			Url:     u("/"),
+			Status:  200,
+			Outcome: domain.OutcomeOk,
+			Links: []*domain.Link{
+				link("/", "/about", "About"),
+				link("/", "/missing", "Missing"),
+				link("/", "/down", "Down"),
+			},
+		},
+		{
+			Url:     u("/about"),
+			Status:  200,
+			Outcome: domain.OutcomeOk,
+			Links:   []*domain.Link{link("/about", "/missing", "Gone")},
+		},
+		{
+			Url:     u("/missing"),
+			Status:  404,
+			Outcome: domain.OutcomeOk,
+			Links:   []*domain.Link{link("/missing", "/missing", "Self")},
+		},
+		{Url: u("/down"), Outcome: domain.OutcomeError, Error: "dial tcp: i/o timeout"},
+		{Url: u("/deep"), Outcome: domain.OutcomeSkipped},
+	}
+
+	broken := FindBrokenTargets(pages)
+	assert.Equal(t, []*BrokenTarget{
+		{
+			Url:   "http://example.com/down",
+			Error: "dial tcp: i/o timeout",
+			Links: []*Referrer{
+				{Source: "http://example.com/", Type: domain.LinkAnchor, Text: "Down"},
+			},
+		},
+		{
+			Url:    "http://example.com/missing",
+			Status: 404,
+			Links: []*Referrer{
+				{Source: "http://example.com/", Type: domain.LinkAnchor, Text: "Missing"},
+				{Source: "http://example.com/about", Type: domain.LinkAnchor, Text: "Gone"},
+			},
+		},
+	}, broken)
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteBrokenTargets(&buf, broken))
+	assert.Contains(t, buf.String(), "2 broken pages:\n")
+	assert.Contains(t, buf.String(), "\nhttp://example.com/missing\t404\n\thttp://example.com/\tanchor\t\"Missing\"\n")
+}
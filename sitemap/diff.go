@@ -0,0 +1,308 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+var (
+	UnknownSchemaVersion = errors.New("JSON site structure is from a newer version of kraken")
+)
+
+// Diff is the difference between two crawls of a site
+type Diff struct {
+	Added          []string        `json:"added"`
+	Removed        []string        `json:"removed"`
+	StatusChanged  []*StatusChange `json:"status_changed"`
+	TitleChanged   []*TitleChange  `json:"title_changed"`
+	NewBrokenLinks []*BrokenLink   `json:"new_broken_links"`
+	LinksChanged   []*LinkChange   `json:"links_changed"`
+}
+
+// StatusChange is a page which responded differently, including
+// any error fetching it
+type StatusChange struct {
+	Url      string `json:"url"`
+	Old      int    `json:"old"`
+	New      int    `json:"new"`
+	OldError string `json:"old_error,omitempty"`
+	NewError string `json:"new_error,omitempty"`
+}
+
+// TitleChange is a page whose title changed
+type TitleChange struct {
+	Url string `json:"url"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// BrokenLink is a link to a page which failed, or responded
+// with a client or server error
+type BrokenLink struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// LinkChange lists the links added to and removed from a page
+type LinkChange struct {
+	Url     string   `json:"url"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// siteStructure is a JSON site structure, as built by BuildJSONSiteStructure
+type siteStructure struct {
+	SchemaVersion int              `json:"schema_version"`
+	Target        string           `json:"target"`
+	Pages         []*formattedPage `json:"pages"`
+}
+
+// readSiteStructure reads a JSON site structure, indexing the pages
+// which were fetched, successfully or not, by URL
+func readSiteStructure(r io.Reader) (map[string]*formattedPage, error) {
+	s := &siteStructure{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, err
+	}
+	if s.SchemaVersion > JSONSchemaVersion {
+		return nil, UnknownSchemaVersion
+	}
+
+	ret := make(map[string]*formattedPage)
+	for _, p := range s.Pages {
+		switch p.Outcome {
+		case "", domain.OutcomeOk, domain.OutcomeError:
+			ret[p.Url] = p
+		}
+	}
+
+	return ret, nil
+}
+
+// broken determines if a page failed, or responded with an error
+func (p *formattedPage) broken() bool {
+	return p.Outcome == domain.OutcomeError || p.Status >= http.StatusBadRequest
+}
+
+// DiffJSONSiteStructures compares two JSON site structures, as built by
+// BuildJSONSiteStructure, from an old and a new crawl of a site
+func DiffJSONSiteStructures(oldr, newr io.Reader) (*Diff, error) {
+	old, err := readSiteStructure(oldr)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read old site structure: %v", err)
+	}
+	cur, err := readSiteStructure(newr)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read new site structure: %v", err)
+	}
+
+	d := &Diff{
+		Added:          make([]string, 0),
+		Removed:        make([]string, 0),
+		StatusChanged:  make([]*StatusChange, 0),
+		TitleChanged:   make([]*TitleChange, 0),
+		NewBrokenLinks: make([]*BrokenLink, 0),
+		LinksChanged:   make([]*LinkChange, 0),
+	}
+
+	for u := range old {
+		if _, ok := cur[u]; !ok {
+			d.Removed = append(d.Removed, u)
+		}
+	}
+
+	for u, p := range cur {
+		o, ok := old[u]
+		if !ok {
+			d.Added = append(d.Added, u)
+		}
+
+		// Links to broken pages, unless they were already broken
+		if p.broken() {
+			var before map[string]bool
+			if ok && o.broken() {
+				before = linkingPages(old, u)
+			}
+			for source := range linkingPages(cur, u) {
+				if !before[source] {
+					d.NewBrokenLinks = append(d.NewBrokenLinks, &BrokenLink{
+						Source: source,
+						Target: u,
+						Status: p.Status,
+						Error:  p.Error,
+					})
+				}
+			}
+		}
+
+		if !ok {
+			continue
+		}
+
+		if o.Status != p.Status || o.Error != p.Error {
+			d.StatusChanged = append(d.StatusChanged, &StatusChange{
+				Url:      u,
+				Old:      o.Status,
+				New:      p.Status,
+				OldError: o.Error,
+				NewError: p.Error,
+			})
+		}
+
+		if o.Title != p.Title {
+			d.TitleChanged = append(d.TitleChanged, &TitleChange{
+				Url: u,
+				Old: o.Title,
+				New: p.Title,
+			})
+		}
+
+		added, removed := diffStrings(o.Links, p.Links)
+		if len(added) > 0 || len(removed) > 0 {
+			d.LinksChanged = append(d.LinksChanged, &LinkChange{
+				Url:     u,
+				Added:   added,
+				Removed: removed,
+			})
+		}
+	}
+
+	d.sort()
+
+	return d, nil
+}
+
+// linkingPages returns the pages with a link to the target
+func linkingPages(pages map[string]*formattedPage, target string) map[string]bool {
+	ret := make(map[string]bool)
+	for u, p := range pages {
+		for _, l := range p.Links {
+			if l == target && u != target {
+				ret[u] = true
+			}
+		}
+	}
+
+	return ret
+}
+
+// diffStrings returns the distinct values added to and
+// removed from a list, sorted
+func diffStrings(old, cur []string) ([]string, []string) {
+	before := make(map[string]bool)
+	for _, s := range old {
+		before[s] = true
+	}
+	after := make(map[string]bool)
+	for _, s := range cur {
+		after[s] = true
+	}
+
+	added, removed := make([]string, 0), make([]string, 0)
+	for s := range after {
+		if !before[s] {
+			added = append(added, s)
+		}
+	}
+	for s := range before {
+		if !after[s] {
+			removed = append(removed, s)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	return added, removed
+}
+
+func (d *Diff) sort() {
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Slice(d.StatusChanged, func(i, j int) bool {
+		return d.StatusChanged[i].Url < d.StatusChanged[j].Url
+	})
+	sort.Slice(d.TitleChanged, func(i, j int) bool {
+		return d.TitleChanged[i].Url < d.TitleChanged[j].Url
+	})
+	sort.Slice(d.NewBrokenLinks, func(i, j int) bool {
+		a, b := d.NewBrokenLinks[i], d.NewBrokenLinks[j]
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Source < b.Source
+	})
+	sort.Slice(d.LinksChanged, func(i, j int) bool {
+		return d.LinksChanged[i].Url < d.LinksChanged[j].Url
+	})
+}
+
+// WriteText writes a human readable report of the differences
+func (d *Diff) WriteText(w io.Writer) error {
+	ew := &errWriter{w: w}
+
+	ew.printf("%v pages added:\n", len(d.Added))
+	for _, u := range d.Added {
+		ew.printf("\t%s\n", u)
+	}
+
+	ew.printf("\n%v pages removed:\n", len(d.Removed))
+	for _, u := range d.Removed {
+		ew.printf("\t%s\n", u)
+	}
+
+	ew.printf("\n%v status changes:\n", len(d.StatusChanged))
+	for _, s := range d.StatusChanged {
+		ew.printf("\t%s\t%s -> %s\n", s.Url, statusText(s.Old, s.OldError), statusText(s.New, s.NewError))
+	}
+
+	ew.printf("\n%v new broken links:\n", len(d.NewBrokenLinks))
+	for _, l := range d.NewBrokenLinks {
+		ew.printf("\t%s -> %s\t%s\n", l.Source, l.Target, statusText(l.Status, l.Error))
+	}
+
+	ew.printf("\n%v title changes:\n", len(d.TitleChanged))
+	for _, t := range d.TitleChanged {
+		ew.printf("\t%s\t%q -> %q\n", t.Url, t.Old, t.New)
+	}
+
+	ew.printf("\n%v pages with changed links:\n", len(d.LinksChanged))
+	for _, l := range d.LinksChanged {
+		ew.printf("\t%s\n", l.Url)
+		for _, u := range l.Added {
+			ew.printf("\t\t+ %s\n", u)
+		}
+		for _, u := range l.Removed {
+			ew.printf("\t\t- %s\n", u)
+		}
+	}
+
+	return ew.err
+}
+
+// statusText describes a response by its error, or status code
+func statusText(status int, err string) string {
+	if err != "" {
+		return err
+	}
+	return fmt.Sprint(status)
+}
+
+// errWriter writes formatted text until the first error
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, a ...interface{}) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, a...)
+	}
+}
@@ -0,0 +1,88 @@
+package sitemap
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+func TestDiffJSONSiteStructures(t *testing.T) {
+	target := &url.URL{Scheme: "http", Host: "example.com", Path: "/"}
+	u := func(path string) *url.URL {
+		return target.ResolveReference(&url.URL{Path: path})
+	}
+	page := func(path string, status int, title string, links ...string) *domain.Page {
+		p := &domain.Page{
+			Url:     u(path),
+			Status:  status,
+			Title:   title,
+			Outcome: domain.OutcomeOk,
+		}
+		for _, l := range links {
+			p.Links = append(p.Links, &domain.Link{Source: p.Url, Target: u(l), Type: domain.LinkAnchor})
+		}
+		return p
+	}
+
+	old, err := BuildJSONSiteStructure(target, []*domain.Page{
+		page("/", 200, "Home", "/about", "/old", "/missing"),
+		page("/about", 200, "About"),
+		page("/old", 200, "Old"),
+		page("/missing", 404, "Not found"),
+	})
+	assert.NoError(t, err)
+
+	down := page("/about", 0, "")
+	down.Outcome = domain.OutcomeError
+	down.Error = "connection refused"
+	cur, err := BuildJSONSiteStructure(target, []*domain.Page{
+		page("/", 200, "Welcome", "/about", "/new", "/missing"),
+		down,
+		page("/new", 404, "Not found"),
+		page("/missing", 404, "Not found"),
+		{Url: u("/deep"), Outcome: domain.OutcomeSkipped},
+	})
+	assert.NoError(t, err)
+
+	d, err := DiffJSONSiteStructures(bytes.NewReader(old), bytes.NewReader(cur))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"http://example.com/new"}, d.Added)
+	assert.Equal(t, []string{"http://example.com/old"}, d.Removed)
+	assert.Equal(t, []*StatusChange{
+		{Url: "http://example.com/about", Old: 200, NewError: "connection refused"},
+	}, d.StatusChanged)
+	assert.Equal(t, []*TitleChange{
+		{Url: "http://example.com/", Old: "Home", New: "Welcome"},
+		{Url: "http://example.com/about", Old: "About"},
+	}, d.TitleChanged)
+
+	// Links to /missing were already broken
+	assert.Equal(t, []*BrokenLink{
+		{Source: "http://example.com/", Target: "http://example.com/about", Error: "connection refused"},
+		{Source: "http://example.com/", Target: "http://example.com/new", Status: 404},
+	}, d.NewBrokenLinks)
+
+	assert.Equal(t, []*LinkChange{
+		{Url: "http://example.com/", Added: []string{"http://example.com/new"}, Removed: []string{"http://example.com/old"}},
+	}, d.LinksChanged)
+
+	var buf bytes.Buffer
+	assert.NoError(t, d.WriteText(&buf))
+	assert.Contains(t, buf.String(), "\thttp://example.com/about\t200 -> connection refused\n")
+	assert.Contains(t, buf.String(), "\thttp://example.com/ -> http://example.com/new\t404\n")
+	assert.Contains(t, buf.String(), "\thttp://example.com/\n\t\t+ http://example.com/new\n\t\t- http://example.com/old\n")
+}
+
+func TestDiffJSONSiteStructuresNewerSchema(t *testing.T) {
+	old := `{"schema_version": 1, "pages": []}`
+	cur := `{"schema_version": 99, "pages": []}`
+
+	_, err := DiffJSONSiteStructures(strings.NewReader(old), strings.NewReader(cur))
+	assert.Error(t, err)
+}
@@ -0,0 +1,149 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+// Problems found with hreflang annotations
+const (
+	HreflangInvalidLang = "invalid language code"
+	HreflangNoReturn    = "missing return link"
+)
+
+// hreflangDefault matches pages for users with no better match
+const hreflangDefault = "x-default"
+
+// ISO 639-1 languages and ISO 3166-1 alpha-2 regions, which are
+// the codes search engines accept in hreflang annotations
+var (
+	hreflangLanguages = codeSet(`aa ab ae af ak am an ar as av ay az ba be bg bh bi bm bn bo br bs ca ce ch co
+		cr cs cu cv cy da de dv dz ee el en eo es et eu fa ff fi fj fo fr fy ga gd gl gn gu gv ha he hi ho hr
+		ht hu hy hz ia id ie ig ii ik io is it iu ja jv ka kg ki kj kk kl km kn ko kr ks ku kv kw ky la lb lg
+		li ln lo lt lu lv mg mh mi mk ml mn mr ms mt my na nb nd ne ng nl nn no nr nv ny oc oj om or os pa pi
+		pl ps pt qu rm rn ro ru rw sa sc sd se sg si sk sl sm sn so sq sr ss st su sv sw ta te tg th ti tk tl
+		tn to tr ts tt tw ty ug uk ur uz ve vi vo wa wo xh yi yo za zh zu`)
+
+	hreflangRegions = codeSet(`ad ae af ag ai al am ao aq ar as at au aw ax az ba bb bd be bf bg bh bi bj bl
+		bm bn bo bq br bs bt bv bw by bz ca cc cd cf cg ch ci ck cl cm cn co cr cu cv cw cx cy cz de dj dk dm
+		do dz ec ee eg eh er es et fi fj fk fm fo fr ga gb gd ge gf gg gh gi gl gm gn gp gq gr gs gt gu gw gy
+		hk hm hn hr ht hu id ie il im in io iq ir is it je jm jo jp ke kg kh ki km kn kp kr kw ky kz la lb lc
+		li lk lr ls lt lu lv ly ma mc md me mf mg mh mk ml mm mn mo mp mq mr ms mt mu mv mw mx my mz na nc ne
+		nf ng ni nl no np nr nu nz om pa pe pf pg ph pk pl pm pn pr ps pt pw py qa re ro rs ru rw sa sb sc sd
+		se sg sh si sj sk sl sm sn so sr ss st sv sx sy sz tc td tf tg th tj tk tl tm tn to tr tt tv tw tz ua
+		ug um us uy uz va vc ve vg vi vn vu wf ws ye yt za zm zw`)
+)
+
+// HreflangIssue is a problem with a single hreflang annotation
+type HreflangIssue struct {
+	Page      *url.URL
+	Lang      string
+	Alternate *url.URL
+	Problem   string
+}
+
+func (i *HreflangIssue) String() string {
+	return fmt.Sprintf("%s: %s for hreflang %s -> %s", i.Page, i.Problem, i.Lang, i.Alternate)
+}
+
+// ValidateHreflang checks the hreflang annotations across a site. Each
+// language code must be valid, and each alternate page must link back
+// to the page annotating it. Alternates which weren't crawled can't
+// be checked, so aren't reported.
+func ValidateHreflang(pages []*domain.Page) []*HreflangIssue {
+	issues := make([]*HreflangIssue, 0)
+
+	// Where each crawled page says its alternates are
+	alternates := make(map[string]map[string]bool)
+	for _, p := range pages {
+		if p == nil || p.Url == nil {
+			continue
+		}
+		alternates[p.Url.String()] = make(map[string]bool)
+		for _, a := range p.Alternates {
+			alternates[p.Url.String()][a.Url.String()] = true
+		}
+	}
+
+	for _, p := range pages {
+		if p == nil || p.Url == nil {
+			continue
+		}
+
+		for _, a := range p.Alternates {
+			issue := &HreflangIssue{
+				Page:      p.Url,
+				Lang:      a.Lang,
+				Alternate: a.Url,
+			}
+
+			if !ValidHreflang(a.Lang) {
+				issue.Problem = HreflangInvalidLang
+				issues = append(issues, issue)
+				continue
+			}
+
+			// Pages needn't link back to themselves
+			if a.Url.String() == p.Url.String() {
+				continue
+			}
+
+			if back, ok := alternates[a.Url.String()]; ok && !back[p.Url.String()] {
+				issue.Problem = HreflangNoReturn
+				issues = append(issues, issue)
+			}
+		}
+	}
+
+	return issues
+}
+
+// ValidHreflang determines if an hreflang code is valid, being x-default
+// or a language, optionally followed by a script and a region, eg. en-GB
+// or zh-Hant-TW
+func ValidHreflang(code string) bool {
+	if strings.EqualFold(code, hreflangDefault) {
+		return true
+	}
+
+	parts := strings.Split(strings.ToLower(code), "-")
+	if !hreflangLanguages[parts[0]] {
+		return false
+	}
+	parts = parts[1:]
+
+	// Optional script, eg. Hant
+	if len(parts) > 0 && len(parts[0]) == 4 && isLetters(parts[0]) {
+		parts = parts[1:]
+	}
+
+	// Optional region, eg. GB
+	if len(parts) > 0 && hreflangRegions[parts[0]] {
+		parts = parts[1:]
+	}
+
+	return len(parts) == 0
+}
+
+// codeSet builds a set from a whitespace separated list of codes
+func codeSet(codes string) map[string]bool {
+	ret := make(map[string]bool)
+	for _, c := range strings.Fields(codes) {
+		ret[c] = true
+	}
+
+	return ret
+}
+
+func isLetters(s string) bool {
+	for _, c := range s {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,80 @@
+package sitemap
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+func TestValidHreflang(t *testing.T) {
+	testCases := map[string]bool{
+		"en":         true,
+		"en-GB":      true,
+		"en-gb":      true,
+		"zh-Hant":    true,
+		"zh-Hant-TW": true,
+		"x-default":  true,
+		"en-UK":      false,
+		"en_GB":      false,
+		"english":    false,
+		"gb":         false,
+		"en-GB-GB":   false,
+		"":           false,
+	}
+
+	for code, expected := range testCases {
+		assert.Equal(t, expected, ValidHreflang(code), code)
+	}
+}
+
+func TestValidateHreflang(t *testing.T) {
+	en := &url.URL{Scheme: "http", Host: "example.com", Path: "/en/"}
+	fr := &url.URL{Scheme: "http", Host: "example.com", Path: "/fr/"}
+	de := &url.URL{Scheme: "http", Host: "example.com", Path: "/de/"}
+	es := &url.URL{Scheme: "http", Host: "example.com", Path: "/es/"}
+
+	pages := []*domain.Page{
+		&domain.Page{
+			Url: en,
+			Alternates: []*domain.Alternate{
+				&domain.Alternate{Lang: "en", Url: en},
+				&domain.Alternate{Lang: "fr", Url: fr},
+				&domain.Alternate{Lang: "de", Url: de},
+				&domain.Alternate{Lang: "es", Url: es},
+			},
+		},
+		&domain.Page{
+			Url: fr,
+			Alternates: []*domain.Alternate{
+				&domain.Alternate{Lang: "en", Url: en},
+				&domain.Alternate{Lang: "fr-FX", Url: fr},
+			},
+		},
+
+		// German doesn't link back to English, and Spanish wasn't crawled
+		&domain.Page{
+			Url: de,
+		},
+	}
+
+	found := make([]string, 0)
+	for _, i := range ValidateHreflang(pages) {
+		found = append(found, i.String())
+	}
+
+	assert.Equal(t, []string{
+		"http://example.com/en/: missing return link for hreflang de -> http://example.com/de/",
+		"http://example.com/fr/: invalid language code for hreflang fr-FX -> http://example.com/fr/",
+	}, found)
+
+	// Invalid codes are left out of sitemaps
+	b, err := BuildXMLSitemap(pages, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `<loc>http://example.com/fr/</loc>
+		<priority>1.0</priority>
+		<xhtml:link rel="alternate" hreflang="en" href="http://example.com/en/"></xhtml:link>
+	</url>`)
+}
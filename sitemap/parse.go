@@ -0,0 +1,59 @@
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// gzipMagic identifies gzipped sitemaps, which aren't always
+// served with a Content-Encoding we can rely on
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// parsedSitemap holds either a urlset or a sitemapindex, as both
+// list their locations in the same way
+type parsedSitemap struct {
+	XMLName  xml.Name
+	Urls     []string `xml:"url>loc"`
+	Sitemaps []string `xml:"sitemap>loc"`
+}
+
+// ParseXMLSitemap reads a sitemap, or sitemap index, which may be gzipped.
+// The URLs listed are returned, along with any further sitemaps listed
+// by an index.
+func ParseXMLSitemap(r io.Reader) (urls []string, sitemaps []string, err error) {
+	br := bufio.NewReader(r)
+
+	var in io.Reader = br
+	if magic, _ := br.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer gz.Close()
+		in = gz
+	}
+
+	ps := &parsedSitemap{}
+	if err := xml.NewDecoder(in).Decode(ps); err != nil {
+		return nil, nil, err
+	}
+
+	return trimAll(ps.Urls), trimAll(ps.Sitemaps), nil
+}
+
+// trimAll removes surrounding whitespace from locations, which
+// sitemaps frequently include
+func trimAll(strs []string) []string {
+	ret := make([]string, 0, len(strs))
+	for _, s := range strs {
+		if s = strings.TrimSpace(s); s != "" {
+			ret = append(ret, s)
+		}
+	}
+
+	return ret
+}
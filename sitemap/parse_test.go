@@ -0,0 +1,55 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testUrlset = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>http://example.com/</loc></url>
+	<url>
+		<loc>
+			http://example.com/search-only?a=1&amp;b=2
+		</loc>
+		<lastmod>2014-06-01</lastmod>
+	</url>
+</urlset>`
+
+	testIndex = `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>http://example.com/sitemap-1.xml</loc></sitemap>
+	<sitemap><loc>http://example.com/sitemap-2.xml.gz</loc></sitemap>
+</sitemapindex>`
+)
+
+func TestParseXMLSitemap(t *testing.T) {
+	urls, sitemaps, err := ParseXMLSitemap(strings.NewReader(testUrlset))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"http://example.com/", "http://example.com/search-only?a=1&b=2"}, urls)
+	assert.Empty(t, sitemaps)
+
+	urls, sitemaps, err = ParseXMLSitemap(strings.NewReader(testIndex))
+	assert.Nil(t, err)
+	assert.Empty(t, urls)
+	assert.Equal(t, []string{"http://example.com/sitemap-1.xml", "http://example.com/sitemap-2.xml.gz"}, sitemaps)
+
+	_, _, err = ParseXMLSitemap(strings.NewReader("<html>Not a sitemap"))
+	assert.NotNil(t, err)
+}
+
+func TestParseGzippedXMLSitemap(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(testUrlset))
+	gz.Close()
+
+	urls, _, err := ParseXMLSitemap(&buf)
+	assert.Nil(t, err)
+	assert.Len(t, urls, 2)
+}
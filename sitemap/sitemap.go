@@ -3,54 +3,85 @@ package sitemap
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io"
 	"net/url"
 	"time"
 
+	"github.com/mattheath/kraken/analysis"
 	"github.com/mattheath/kraken/domain"
 )
 
+// JSONSchemaVersion is the version of our JSON site structure, which
+// is incremented whenever the structure changes incompatibly
+const JSONSchemaVersion = 1
+
 const (
-	sitemapTemplateHeader = `<?xml version="1.0" encoding="utf-8"?>
-<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
-   xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
-   xsi:schemaLocation="http://www.sitemaps.org/schemas/sitemap/0.9 http://www.sitemaps.org/schemas/sitemap/0.9/sitemap.xsd">
-`
-
-	sitemapTemplateFooter = `</urlset>`
-
-	urlTemplate = `	<url>
-		<loc>%s</loc>
-		<lastmod>%s</lastmod>
-		<changefreq>daily</changefreq>
-		<priority>0.8</priority>
-	</url>
-`
+	// Limits on each sitemap imposed by the sitemap protocol
+	MaxSitemapUrls  = 50000
+	MaxSitemapBytes = 50 * 1024 * 1024
+
+	// Limit on the images listed for each URL by the image extension
+	MaxImagesPerUrl = 1000
+)
+
+var (
+	UrlTooLarge = errors.New("URL entry is too large to fit in a sitemap")
 )
 
 type formattedPage struct {
-	Url    string   `json:"url"`
-	Links  []string `json:"links"`
-	Assets []string `json:"assets"`
+	Url          string   `json:"url"`
+	Status       int      `json:"status,omitempty"`
+	Title        string   `json:"title,omitempty"`
+	Links        []string `json:"links"`
+	Assets       []string `json:"assets"`
+	Source       string   `json:"source,omitempty"`
+	Charset      string   `json:"charset,omitempty"`
+	Depth        int      `json:"depth"`
+	LastModified string   `json:"last_modified,omitempty"`
+	Outcome      string   `json:"outcome,omitempty"`
+	Error        string   `json:"error,omitempty"`
+
+	// Metrics of pages crawled successfully, once the crawl is complete
+	Metrics *analysis.PageMetrics `json:"metrics,omitempty"`
 }
 
-// BuildXMLSitemap builds a standard XML sitemap from a list of pages on a site
-func BuildXMLSitemap(pages []*domain.Page) ([]byte, error) {
+// BuildXMLSitemap builds a standard XML sitemap from a list of pages on a site,
+// deriving values for each page as specified by opts, which may be nil
+func BuildXMLSitemap(pages []*domain.Page, opts *Options) ([]byte, error) {
 	var buf bytes.Buffer
 
-	// Add the header with schema definition
-	buf.WriteString(sitemapTemplateHeader)
+	sw, err := NewWriter(&buf, opts != nil && opts.Gzip)
+	if err != nil {
+		return nil, err
+	}
 
 	// Add each page
+	v := newValuer(pages, opts)
 	for _, p := range pages {
 		if p == nil || p.Url == nil {
 			continue
 		}
-		buf.WriteString(fmt.Sprintf(urlTemplate, p.Url.String(), time.Now().Format("2006-01-02")))
+		if err := sw.WriteUrl(newUrl(p, v.values(p), v.opts)); err != nil {
+			return nil, err
+		}
 	}
 
-	// Append the footer closing tag
-	buf.WriteString(sitemapTemplateFooter)
+	if err := sw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// BuildXMLSitemapIndex builds a sitemap index referencing
+// sitemaps hosted at the specified locations
+func BuildXMLSitemapIndex(locations []*url.URL) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := WriteXMLSitemapIndex(&buf, locations); err != nil {
+		return nil, err
+	}
 
 	return buf.Bytes(), nil
 }
@@ -58,28 +89,67 @@ func BuildXMLSitemap(pages []*domain.Page) ([]byte, error) {
 func BuildJSONSiteStructure(target *url.URL, pages []*domain.Page) ([]byte, error) {
 
 	ret := map[string]interface{}{
-		"target": target.String(),
+		"schema_version": JSONSchemaVersion,
+		"target":         target.String(),
 	}
 
+	metrics := analysis.LinkMetrics(pages)
+
 	ps := []*formattedPage{}
 	for _, p := range pages {
-		fp := &formattedPage{
-			Url: p.Url.String(),
-		}
-
-		fp.Links = make([]string, len(p.Links))
-		for i, l := range p.Links {
-			fp.Links[i] = l.Target.String()
-		}
-
-		fp.Assets = make([]string, len(p.Assets))
-		for i, a := range p.Assets {
-			fp.Assets[i] = a.String()
-		}
-
+		fp := formatPage(p)
+		fp.Metrics = metrics[fp.Url]
 		ps = append(ps, fp)
 	}
 	ret["pages"] = ps
+	ret["tree"] = BuildTree(pages)
 
 	return json.Marshal(ret)
 }
+
+// JSONLinesWriter streams pages as JSON Lines, with a JSON
+// object on a single line for each page
+type JSONLinesWriter struct {
+	enc *json.Encoder
+}
+
+// NewJSONLinesWriter initialises a JSONLinesWriter writing to w
+func NewJSONLinesWriter(w io.Writer) *JSONLinesWriter {
+	return &JSONLinesWriter{
+		enc: json.NewEncoder(w),
+	}
+}
+
+// WritePage writes a single page, formatted as in BuildJSONSiteStructure
+func (j *JSONLinesWriter) WritePage(p *domain.Page) error {
+	return j.enc.Encode(formatPage(p))
+}
+
+// formatPage formats a page for JSON output
+func formatPage(p *domain.Page) *formattedPage {
+	fp := &formattedPage{
+		Url:     p.Url.String(),
+		Status:  p.Status,
+		Title:   p.Title,
+		Source:  p.Source,
+		Charset: p.Charset,
+		Depth:   p.Depth,
+		Outcome: p.Outcome,
+		Error:   p.Error,
+	}
+	if !p.LastModified.IsZero() {
+		fp.LastModified = p.LastModified.UTC().Format(time.RFC3339)
+	}
+
+	fp.Links = make([]string, len(p.Links))
+	for i, l := range p.Links {
+		fp.Links[i] = l.Target.String()
+	}
+
+	fp.Assets = make([]string, len(p.Assets))
+	for i, a := range p.Assets {
+		fp.Assets[i] = a.Url.String()
+	}
+
+	return fp
+}
@@ -0,0 +1,255 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+// nopCloser lets buffers stand in for sitemap files
+type nopCloser struct {
+	*bytes.Buffer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// writeSitemaps writes pages into in memory sitemaps
+func writeSitemaps(pages []*domain.Page, opts *Options, maxUrls, maxBytes int) ([][]byte, error) {
+	bufs := make([]*bytes.Buffer, 0)
+	create := func(n int) (io.WriteCloser, error) {
+		bufs = append(bufs, &bytes.Buffer{})
+		return nopCloser{bufs[n-1]}, nil
+	}
+
+	n, err := WriteXMLSitemaps(pages, opts, create, maxUrls, maxBytes)
+
+	ret := make([][]byte, n)
+	for i := range ret {
+		ret[i] = bufs[i].Bytes()
+	}
+
+	return ret, err
+}
+
+func TestWriteXMLSitemapsSplitsByCount(t *testing.T) {
+	pages := testPages(5)
+
+	sitemaps, err := writeSitemaps(pages, nil, 2, MaxSitemapBytes)
+	assert.Nil(t, err)
+	assert.Len(t, sitemaps, 3)
+
+	for i, expected := range []int{2, 2, 1} {
+		assert.Equal(t, expected, bytes.Count(sitemaps[i], []byte("<url>")))
+		assert.True(t, bytes.HasPrefix(sitemaps[i], []byte(sitemapHeader)))
+		assert.True(t, bytes.HasSuffix(sitemaps[i], []byte(sitemapFooter)))
+	}
+
+	// A single sitemap matches the unsplit output
+	sitemaps, err = writeSitemaps(pages, nil, MaxSitemapUrls, MaxSitemapBytes)
+	assert.Nil(t, err)
+	single, _ := BuildXMLSitemap(pages, nil)
+	assert.Equal(t, [][]byte{single}, sitemaps)
+}
+
+func TestWriteXMLSitemapsSplitsBySize(t *testing.T) {
+	pages := testPages(10)
+	entry, _ := encodeEntry(newUrl(pages[0], &urlValues{priority: 1}, nil))
+	limit := len(sitemapHeader) + len(sitemapFooter) + 3*len(entry)
+
+	sitemaps, err := writeSitemaps(pages, nil, MaxSitemapUrls, limit)
+	assert.Nil(t, err)
+	assert.Len(t, sitemaps, 4)
+	for _, s := range sitemaps {
+		assert.True(t, len(s) <= limit)
+	}
+
+	_, err = writeSitemaps(pages, nil, MaxSitemapUrls, len(entry))
+	assert.Equal(t, UrlTooLarge, err)
+
+	// No pages still gives a valid, empty, sitemap
+	sitemaps, err = writeSitemaps(nil, nil, MaxSitemapUrls, MaxSitemapBytes)
+	assert.Nil(t, err)
+	assert.Len(t, sitemaps, 1)
+}
+
+func TestWriteXMLSitemapsGzip(t *testing.T) {
+	pages := testPages(3)
+
+	sitemaps, err := writeSitemaps(pages, &Options{Gzip: true}, 2, MaxSitemapBytes)
+	assert.Nil(t, err)
+	assert.Len(t, sitemaps, 2)
+
+	// Our own parser should read the compressed sitemaps
+	urls, _, err := ParseXMLSitemap(bytes.NewReader(sitemaps[0]))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"http://example.com/page/000", "http://example.com/page/001"}, urls)
+}
+
+func TestBuildXMLSitemapEscaping(t *testing.T) {
+	pages := []*domain.Page{
+		&domain.Page{
+			Url: &url.URL{Scheme: "http", Host: "example.com", Path: "/search", RawQuery: "q=kraken&page=2"},
+		},
+	}
+
+	b, err := BuildXMLSitemap(pages, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "<loc>http://example.com/search?q=kraken&amp;page=2</loc>")
+
+	urls, _, err := ParseXMLSitemap(bytes.NewReader(b))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"http://example.com/search?q=kraken&page=2"}, urls)
+}
+
+func TestBuildXMLSitemapIndex(t *testing.T) {
+	locations := []*url.URL{
+		&url.URL{Scheme: "http", Host: "example.com", Path: "/sitemap-1.xml"},
+		&url.URL{Scheme: "http", Host: "example.com", Path: "/sitemap-2.xml"},
+	}
+
+	index, err := BuildXMLSitemapIndex(locations)
+	assert.Nil(t, err)
+
+	// Our own parser should find the sitemaps again
+	_, sitemaps, err := ParseXMLSitemap(bytes.NewReader(index))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"http://example.com/sitemap-1.xml", "http://example.com/sitemap-2.xml"}, sitemaps)
+}
+
+// testPages returns n pages with URLs of the same length
+func testPages(n int) []*domain.Page {
+	ret := make([]*domain.Page, n)
+	for i := range ret {
+		ret[i] = &domain.Page{
+			Url: &url.URL{Scheme: "http", Host: "example.com", Path: fmt.Sprintf("/page/%03d", i)},
+		}
+	}
+
+	return ret
+}
+
+func TestBuildXMLSitemapValues(t *testing.T) {
+	home := &domain.Page{
+		Url:          &url.URL{Scheme: "http", Host: "example.com", Path: "/"},
+		LastModified: time.Date(2014, 6, 1, 12, 0, 0, 0, time.FixedZone("BST", 3600)),
+	}
+	post := &domain.Page{
+		Url:   &url.URL{Scheme: "http", Host: "example.com", Path: "/blog/kraken"},
+		Depth: 2,
+	}
+
+	b, err := BuildXMLSitemap([]*domain.Page{home, post}, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "<loc>http://example.com/</loc>\n\t\t<lastmod>2014-06-01T11:00:00Z</lastmod>\n\t\t<priority>1.0</priority>")
+
+	// Unknown modification times are omitted rather than guessed
+	assert.Contains(t, string(b), "<loc>http://example.com/blog/kraken</loc>\n\t\t<priority>0.6</priority>")
+
+	rules, err := LoadRules(strings.NewReader(`[{"path": "/blog/", "changefreq": "weekly", "priority": 0.3}]`))
+	assert.Nil(t, err)
+
+	b, err = BuildXMLSitemap([]*domain.Page{home, post}, &Options{Rules: rules})
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "<loc>http://example.com/blog/kraken</loc>\n\t\t<changefreq>weekly</changefreq>\n\t\t<priority>0.3</priority>")
+}
+
+func TestBuildXMLSitemapMedia(t *testing.T) {
+	page := &domain.Page{
+		Url: &url.URL{Scheme: "http", Host: "example.com", Path: "/kraken"},
+		Images: []*domain.Image{
+			&domain.Image{
+				Url:     &url.URL{Scheme: "http", Host: "cdn.example.com", Path: "/kraken.jpg"},
+				Title:   "Kraken",
+				Caption: "Krakens & ships",
+			},
+		},
+		Videos: []*domain.Video{
+			&domain.Video{
+				Url:         &url.URL{Scheme: "http", Host: "example.com", Path: "/attack.mp4"},
+				Thumbnail:   &url.URL{Scheme: "http", Host: "example.com", Path: "/poster.jpg"},
+				Title:       "Kraken attack",
+				Description: "The kraken attacks",
+			},
+			&domain.Video{
+				Url:         &url.URL{Scheme: "http", Host: "example.com", Path: "/untitled.mp4"},
+				Thumbnail:   &url.URL{Scheme: "http", Host: "example.com", Path: "/poster.jpg"},
+				Description: "No title",
+			},
+			&domain.Video{
+				Url:         &url.URL{Scheme: "http", Host: "example.com", Path: "/thumbless.mp4"},
+				Title:       "Kraken",
+				Description: "No thumbnail",
+			},
+		},
+	}
+
+	// Media is only listed if asked for
+	b, err := BuildXMLSitemap([]*domain.Page{page}, nil)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), "<image:image>")
+	assert.NotContains(t, string(b), "<video:video>")
+
+	b, err = BuildXMLSitemap([]*domain.Page{page}, &Options{Images: true, Videos: true})
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `<image:image>
+			<image:loc>http://cdn.example.com/kraken.jpg</image:loc>
+			<image:caption>Krakens &amp; ships</image:caption>
+			<image:title>Kraken</image:title>
+		</image:image>`)
+	assert.Contains(t, string(b), `<video:video>
+			<video:thumbnail_loc>http://example.com/poster.jpg</video:thumbnail_loc>
+			<video:title>Kraken attack</video:title>
+			<video:description>The kraken attacks</video:description>
+			<video:content_loc>http://example.com/attack.mp4</video:content_loc>
+		</video:video>`)
+
+	// Videos without the values search engines require are left out
+	assert.Equal(t, 1, strings.Count(string(b), "<video:video>"))
+	assert.NotContains(t, string(b), "untitled.mp4")
+	assert.NotContains(t, string(b), "thumbless.mp4")
+
+	// Media doesn't confuse our own parser
+	urls, _, err := ParseXMLSitemap(bytes.NewReader(b))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"http://example.com/kraken"}, urls)
+}
+
+func TestJSONLinesWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONLinesWriter(&buf)
+
+	for _, p := range testPages(2) {
+		assert.Nil(t, w.WritePage(p))
+	}
+
+	assert.Equal(t, `{"url":"http://example.com/page/000","links":[],"assets":[],"depth":0}
+{"url":"http://example.com/page/001","links":[],"assets":[],"depth":0}
+`, buf.String())
+}
+
+func TestBuildJSONSiteStructure(t *testing.T) {
+	pages := testPages(2)
+	pages[0].Outcome = domain.OutcomeOk
+	pages[1].Outcome = domain.OutcomeError
+	pages[1].Error = "connection refused"
+
+	b, err := BuildJSONSiteStructure(&url.URL{Scheme: "http", Host: "example.com", Path: "/"}, pages)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"pages":[`+
+		`{"url":"http://example.com/page/000","links":[],"assets":[],"depth":0,"outcome":"ok",`+
+		`"metrics":{"inlinks":0,"outlinks":0,"pagerank":1}},`+
+		`{"url":"http://example.com/page/001","links":[],"assets":[],"depth":0,"outcome":"error","error":"connection refused"}`+
+		`],"schema_version":1,"target":"http://example.com/",`+
+		`"tree":{"segment":"/","path":"/","pages":1,"children":[`+
+		`{"segment":"page","path":"/page","pages":1,"children":[`+
+		`{"segment":"000","path":"/page/000","url":"http://example.com/page/000","pages":1}`+
+		`]}]}}`, string(b))
+}
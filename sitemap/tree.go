@@ -0,0 +1,126 @@
+package sitemap
+
+import (
+	"bytes"
+	"html/template"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+// TreeNode is a segment of the URL paths on a site, such as /docs
+// in /docs/api, with a count of the pages at or under it
+type TreeNode struct {
+	Segment string `json:"segment"`
+	Path    string `json:"path"`
+
+	// Url and Title of the page at this path, if there is one
+	Url   string `json:"url,omitempty"`
+	Title string `json:"title,omitempty"`
+
+	Pages    int         `json:"pages"`
+	Children []*TreeNode `json:"children,omitempty"`
+}
+
+// BuildTree organises the pages crawled successfully by their URL
+// paths, with children sorted by segment. Pages differing only by
+// query string share a node.
+func BuildTree(pages []*domain.Page) *TreeNode {
+	root := &TreeNode{
+		Segment: "/",
+		Path:    "/",
+	}
+
+	for _, p := range pages {
+		if p == nil || p.Url == nil || (p.Outcome != "" && p.Outcome != domain.OutcomeOk) {
+			continue
+		}
+
+		n := root
+		n.Pages++
+		for _, seg := range strings.Split(p.Url.Path, "/") {
+			if seg != "" {
+				n = n.child(seg)
+				n.Pages++
+			}
+		}
+
+		if n.Url == "" {
+			n.Url = p.Url.String()
+			n.Title = p.Title
+		}
+	}
+
+	root.sort()
+
+	return root
+}
+
+// child with the specified segment, added if not yet present
+func (n *TreeNode) child(seg string) *TreeNode {
+	for _, c := range n.Children {
+		if c.Segment == seg {
+			return c
+		}
+	}
+
+	c := &TreeNode{
+		Segment: seg,
+		Path:    strings.TrimSuffix(n.Path, "/") + "/" + seg,
+	}
+	n.Children = append(n.Children, c)
+
+	return c
+}
+
+func (n *TreeNode) sort() {
+	sort.Slice(n.Children, func(i, j int) bool {
+		return n.Children[i].Segment < n.Children[j].Segment
+	})
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
+
+// treeTemplate renders a tree as nested lists, which can be
+// expanded and collapsed without any scripts
+var treeTemplate = template.Must(template.New("tree").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>{{.Target}}</title>
+	<style>
+		body { font-family: sans-serif; }
+		ul { list-style: none; padding-left: 1.5em; }
+		summary { cursor: pointer; }
+		.pages { color: #888; }
+		.title { color: #555; font-style: italic; }
+	</style>
+</head>
+<body>
+	<h1>{{.Target}}</h1>
+	<ul>{{template "node" .Root}}</ul>
+</body>
+</html>
+{{define "node"}}
+<li>{{if .Children}}<details open><summary>{{template "label" .}}</summary><ul>{{range .Children}}{{template "node" .}}{{end}}</ul></details>{{else}}{{template "label" .}}{{end}}</li>
+{{- end}}
+{{define "label"}}{{if .Url}}<a href="{{.Url}}">{{.Segment}}</a>{{else}}{{.Segment}}{{end}} <span class="pages">({{.Pages}})</span>{{if .Title}} <span class="title">{{.Title}}</span>{{end}}{{end}}
+`))
+
+// BuildHTMLTree renders the tree of a site's pages as a collapsible HTML page
+func BuildHTMLTree(target *url.URL, pages []*domain.Page) ([]byte, error) {
+	var buf bytes.Buffer
+
+	err := treeTemplate.Execute(&buf, map[string]interface{}{
+		"Target": target.String(),
+		"Root":   BuildTree(pages),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,72 @@
+package sitemap
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+func TestBuildTree(t *testing.T) {
+	page := func(path, query string) *domain.Page {
+		return &domain.Page{
+			Url: &url.URL{Scheme: "http", Host: "example.com", Path: path, RawQuery: query},
+		}
+	}
+
+	pages := []*domain.Page{
+		page("/", ""),
+		page("/docs/api/v1", ""),
+		page("/docs/", ""),
+		page("/docs/api/v2", ""),
+		page("/blog", ""),
+		page("/blog", "page=2"),
+	}
+	pages[0].Title = "Home"
+
+	// Pages which weren't crawled are left out
+	failed := page("/missing", "")
+	failed.Outcome = domain.OutcomeError
+	pages = append(pages, failed)
+
+	root := BuildTree(pages)
+	assert.Equal(t, 6, root.Pages)
+	assert.Equal(t, "http://example.com/", root.Url)
+	assert.Equal(t, "Home", root.Title)
+	assert.Len(t, root.Children, 2)
+
+	blog, docs := root.Children[0], root.Children[1]
+	assert.Equal(t, "/blog", blog.Path)
+	assert.Equal(t, 2, blog.Pages)
+	assert.Equal(t, "http://example.com/blog", blog.Url)
+
+	assert.Equal(t, "/docs", docs.Path)
+	assert.Equal(t, 3, docs.Pages)
+	assert.Equal(t, "http://example.com/docs/", docs.Url)
+
+	// Intermediate paths needn't be pages themselves
+	api := docs.Children[0]
+	assert.Equal(t, "/docs/api", api.Path)
+	assert.Equal(t, "", api.Url)
+	assert.Equal(t, 2, api.Pages)
+	assert.Equal(t, "/docs/api/v2", api.Children[1].Path)
+}
+
+func TestBuildHTMLTree(t *testing.T) {
+	pages := []*domain.Page{
+		&domain.Page{
+			Url:   &url.URL{Scheme: "http", Host: "example.com", Path: "/"},
+			Title: "Krakens & <ships>",
+		},
+		&domain.Page{
+			Url: &url.URL{Scheme: "http", Host: "example.com", Path: "/about"},
+		},
+	}
+
+	b, err := BuildHTMLTree(&url.URL{Scheme: "http", Host: "example.com", Path: "/"}, pages)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `<summary><a href="http://example.com/">/</a> <span class="pages">(2)</span> <span class="title">Krakens &amp; &lt;ships&gt;</span></summary>`)
+	assert.Contains(t, string(b), `<li><a href="http://example.com/about">about</a> <span class="pages">(1)</span></li>`)
+}
@@ -0,0 +1,219 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/mattheath/kraken/analysis"
+	"github.com/mattheath/kraken/domain"
+)
+
+// Ways of deriving the priority of each page
+const (
+	PriorityDepth    = "depth"
+	PriorityInlinks  = "inlinks"
+	PriorityPageRank = "pagerank"
+)
+
+// minPriority is the lowest priority we derive, so no page is excluded
+const minPriority = 0.1
+
+// changeFreqs are the values allowed by the sitemap protocol
+var changeFreqs = map[string]bool{
+	"always":  true,
+	"hourly":  true,
+	"daily":   true,
+	"weekly":  true,
+	"monthly": true,
+	"yearly":  true,
+	"never":   true,
+}
+
+// Options control how XML sitemaps, and the values in them, are written
+type Options struct {
+	// Priority is derived from each page's depth, inbound links
+	// or internal PageRank
+	Priority string
+
+	// Rules override values for pages under specific paths
+	Rules []*Rule
+
+	// Gzip compresses sitemaps as they are written
+	Gzip bool
+
+	// Images and Videos include each page's embedded media,
+	// using the image and video sitemap extensions
+	Images bool
+	Videos bool
+}
+
+// Rule overrides the values for pages under a path, where the
+// longest matching path takes precedence
+type Rule struct {
+	Path       string   `json:"path"`
+	ChangeFreq string   `json:"changefreq,omitempty"`
+	Priority   *float64 `json:"priority,omitempty"`
+}
+
+// LoadRules reads a JSON array of rules, eg.
+// [{"path": "/blog/", "changefreq": "weekly", "priority": 0.6}]
+func LoadRules(r io.Reader) ([]*Rule, error) {
+	rules := make([]*Rule, 0)
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		if rule.ChangeFreq != "" && !changeFreqs[rule.ChangeFreq] {
+			return nil, fmt.Errorf("Invalid changefreq '%s' for %s", rule.ChangeFreq, rule.Path)
+		}
+		if rule.Priority != nil && (*rule.Priority < 0 || *rule.Priority > 1) {
+			return nil, fmt.Errorf("Invalid priority %v for %s, must be between 0 and 1", *rule.Priority, rule.Path)
+		}
+	}
+
+	return rules, nil
+}
+
+// urlValues are the optional values of a sitemap URL entry
+type urlValues struct {
+	changeFreq string
+	priority   float64
+}
+
+// valuer derives the values for each page from the whole site
+type valuer struct {
+	opts *Options
+
+	// clickDepths from our target, if it's among the pages, as seeds
+	// such as URLs listed in sitemaps are crawled from depth 0 too
+	clickDepths map[string]int
+
+	// metrics are only computed if priority is derived from them
+	metrics     map[string]*analysis.PageMetrics
+	maxInlinks  int
+	maxPageRank float64
+}
+
+// newValuer initialises a valuer for a set of pages
+func newValuer(pages []*domain.Page, opts *Options) *valuer {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	v := &valuer{
+		opts: opts,
+	}
+
+	switch opts.Priority {
+	case "", PriorityDepth:
+		for _, p := range pages {
+			if p != nil && p.Url != nil && p.Source == domain.SourceTarget {
+				v.clickDepths = analysis.ClickDepths(p.Url, pages)
+				break
+			}
+		}
+	case PriorityInlinks, PriorityPageRank:
+		v.metrics = analysis.LinkMetrics(pages)
+		for _, m := range v.metrics {
+			if m.Inlinks > v.maxInlinks {
+				v.maxInlinks = m.Inlinks
+			}
+			v.maxPageRank = math.Max(v.maxPageRank, m.PageRank)
+		}
+	}
+
+	return v
+}
+
+// values for a page, applying any rule matching its path
+func (v *valuer) values(p *domain.Page) *urlValues {
+	ret := &urlValues{}
+
+	switch v.opts.Priority {
+	case PriorityInlinks:
+		ret.priority = v.inlinkPriority(v.metrics[p.Url.String()])
+	case PriorityPageRank:
+		ret.priority = v.pageRankPriority(v.metrics[p.Url.String()])
+	default:
+		ret.priority = v.clickPriority(p)
+	}
+
+	if rule := v.rule(p.Url.Path); rule != nil {
+		if rule.ChangeFreq != "" {
+			ret.changeFreq = rule.ChangeFreq
+		}
+		if rule.Priority != nil {
+			ret.priority = *rule.Priority
+		}
+	}
+
+	return ret
+}
+
+// rule with the longest path matching the specified path
+func (v *valuer) rule(path string) *Rule {
+	var ret *Rule
+	for _, r := range v.opts.Rules {
+		if strings.HasPrefix(path, r.Path) && (ret == nil || len(r.Path) > len(ret.Path)) {
+			ret = r
+		}
+	}
+
+	return ret
+}
+
+// inlinkPriority scales priority logarithmically with the number of
+// inbound links, relative to the most linked to page on the site
+func (v *valuer) inlinkPriority(m *analysis.PageMetrics) float64 {
+	if v.maxInlinks == 0 {
+		return 1
+	}
+
+	n := 0
+	if m != nil {
+		n = m.Inlinks
+	}
+	p := math.Log1p(float64(n)) / math.Log1p(float64(v.maxInlinks))
+	return round(minPriority + (1-minPriority)*p)
+}
+
+// pageRankPriority scales priority linearly with PageRank, relative
+// to the highest ranked page on the site
+func (v *valuer) pageRankPriority(m *analysis.PageMetrics) float64 {
+	if m == nil || v.maxPageRank == 0 {
+		return minPriority
+	}
+
+	return round(minPriority + (1-minPriority)*m.PageRank/v.maxPageRank)
+}
+
+// clickPriority derives priority from how many clicks a page is from our
+// target. Pages which can't be reached from it, such as those only
+// listed in sitemaps, have the lowest priority. Without our target,
+// the depth the page was crawled at is used instead.
+func (v *valuer) clickPriority(p *domain.Page) float64 {
+	if v.clickDepths == nil {
+		return depthPriority(p.Depth)
+	}
+
+	d, ok := v.clickDepths[p.Url.String()]
+	if !ok {
+		return minPriority
+	}
+	return depthPriority(d)
+}
+
+// depthPriority starts at 1 for our target and falls by 0.2
+// for each link followed from it
+func depthPriority(depth int) float64 {
+	return round(math.Max(minPriority, 1-0.2*float64(depth)))
+}
+
+// round priorities to a single decimal place
+func round(p float64) float64 {
+	return math.Floor(p*10+0.5) / 10
+}
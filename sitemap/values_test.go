@@ -0,0 +1,135 @@
+package sitemap
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+func TestPriorityFromDepth(t *testing.T) {
+	testCases := map[int]float64{
+		0:  1.0,
+		1:  0.8,
+		2:  0.6,
+		4:  0.2,
+		5:  0.1,
+		10: 0.1,
+	}
+
+	for depth, expected := range testCases {
+		assert.Equal(t, expected, depthPriority(depth), "depth %v", depth)
+	}
+}
+
+func TestPriorityFromClickDepth(t *testing.T) {
+	pages := []*domain.Page{
+		linkingPage("/", "/a"),
+		linkingPage("/a", "/b"),
+		linkingPage("/b"),
+		linkingPage("/seeded"),
+	}
+	pages[0].Source = domain.SourceTarget
+	pages[2].Source = domain.SourceSitemap
+	pages[3].Source = domain.SourceSitemap
+
+	// Seeds are crawled from depth 0, like our target
+	pages[2].Depth = 0
+	pages[3].Depth = 0
+
+	v := newValuer(pages, nil)
+	assert.Equal(t, 1.0, v.values(pages[0]).priority)
+	assert.Equal(t, 0.8, v.values(pages[1]).priority)
+
+	// Seeds are prioritised by how far they are from our target,
+	// or not at all if they can't be reached from it
+	assert.Equal(t, 0.6, v.values(pages[2]).priority)
+	assert.Equal(t, minPriority, v.values(pages[3]).priority)
+}
+
+func TestPriorityFromInlinks(t *testing.T) {
+	pages := []*domain.Page{
+		linkingPage("/", "/popular", "/popular", "/"),
+		linkingPage("/a", "/popular", "/"),
+		linkingPage("/b", "/popular", "/a"),
+		linkingPage("/popular"),
+		linkingPage("/orphan"),
+	}
+
+	v := newValuer(pages, &Options{Priority: PriorityInlinks})
+
+	// Duplicate links and links to self aren't counted
+	assert.Equal(t, 3, v.metrics["http://example.com/popular"].Inlinks)
+	assert.Equal(t, 1, v.metrics["http://example.com/"].Inlinks)
+
+	assert.Equal(t, 1.0, v.values(pages[3]).priority)
+	assert.Equal(t, 0.1, v.values(pages[4]).priority)
+	assert.True(t, v.values(pages[0]).priority > v.values(pages[2]).priority)
+}
+
+func TestPriorityFromPageRank(t *testing.T) {
+	pages := []*domain.Page{
+		linkingPage("/", "/popular", "/a"),
+		linkingPage("/a", "/popular"),
+		linkingPage("/popular", "/"),
+		linkingPage("/orphan"),
+	}
+
+	v := newValuer(pages, &Options{Priority: PriorityPageRank})
+
+	assert.Equal(t, 1.0, v.values(pages[2]).priority)
+	assert.True(t, v.values(pages[0]).priority > v.values(pages[1]).priority)
+	assert.True(t, v.values(pages[1]).priority > v.values(pages[3]).priority)
+	assert.True(t, v.values(pages[3]).priority >= minPriority)
+}
+
+func TestRules(t *testing.T) {
+	rules, err := LoadRules(strings.NewReader(`[
+		{"path": "/", "changefreq": "daily"},
+		{"path": "/blog/", "changefreq": "weekly", "priority": 0.6},
+		{"path": "/blog/archive/", "priority": 0.1}
+	]`))
+	assert.Nil(t, err)
+
+	v := newValuer(nil, &Options{Rules: rules})
+
+	testCases := map[string]*urlValues{
+		"/":                  &urlValues{"daily", 1.0},
+		"/about":             &urlValues{"daily", 1.0},
+		"/blog/kraken":       &urlValues{"weekly", 0.6},
+		"/blog/archive/2014": &urlValues{"", 0.1},
+	}
+
+	for path, expected := range testCases {
+		assert.Equal(t, expected, v.values(linkingPage(path)), path)
+	}
+
+	// Invalid values are rejected
+	invalid := []string{
+		`[{"path": "/", "changefreq": "fortnightly"}]`,
+		`[{"path": "/", "priority": 1.5}]`,
+		`{"path": "/"}`,
+	}
+	for _, tc := range invalid {
+		_, err := LoadRules(strings.NewReader(tc))
+		assert.NotNil(t, err, tc)
+	}
+}
+
+// linkingPage returns a page at path, linking to each of targets
+func linkingPage(path string, targets ...string) *domain.Page {
+	p := &domain.Page{
+		Url: &url.URL{Scheme: "http", Host: "example.com", Path: path},
+	}
+	for _, t := range targets {
+		p.Links = append(p.Links, &domain.Link{
+			Source: p.Url,
+			Target: &url.URL{Scheme: "http", Host: "example.com", Path: t},
+		})
+	}
+
+	return p
+}
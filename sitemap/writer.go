@@ -0,0 +1,316 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+const (
+	sitemapHeader = `<?xml version="1.0" encoding="utf-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
+   xmlns:image="http://www.google.com/schemas/sitemap-image/1.1"
+   xmlns:video="http://www.google.com/schemas/sitemap-video/1.1"
+   xmlns:xhtml="http://www.w3.org/1999/xhtml"
+   xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+   xsi:schemaLocation="http://www.sitemaps.org/schemas/sitemap/0.9 http://www.sitemaps.org/schemas/sitemap/0.9/sitemap.xsd">
+`
+
+	sitemapFooter = `</urlset>`
+
+	sitemapIndexHeader = `<?xml version="1.0" encoding="utf-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+`
+
+	sitemapIndexFooter = `</sitemapindex>`
+)
+
+// Url is a single entry in a sitemap
+type Url struct {
+	XMLName    xml.Name     `xml:"url"`
+	Loc        string       `xml:"loc"`
+	LastMod    string       `xml:"lastmod,omitempty"`
+	ChangeFreq string       `xml:"changefreq,omitempty"`
+	Priority   string       `xml:"priority,omitempty"`
+	Alternates []*Alternate `xml:"xhtml:link"`
+	Images     []*Image     `xml:"image:image"`
+	Videos     []*Video     `xml:"video:video"`
+}
+
+// Alternate is another language version of a URL
+type Alternate struct {
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+// Image is an entry in the image sitemap extension
+type Image struct {
+	Loc     string `xml:"image:loc"`
+	Caption string `xml:"image:caption,omitempty"`
+	Title   string `xml:"image:title,omitempty"`
+}
+
+// Video is an entry in the video sitemap extension. Search engines
+// require a thumbnail, title and description, so videos are only
+// listed when all of these were found.
+type Video struct {
+	ThumbnailLoc string `xml:"video:thumbnail_loc"`
+	Title        string `xml:"video:title"`
+	Description  string `xml:"video:description"`
+	ContentLoc   string `xml:"video:content_loc"`
+}
+
+// indexEntry is a single sitemap referenced by a sitemap index
+type indexEntry struct {
+	XMLName xml.Name `xml:"sitemap"`
+	Loc     string   `xml:"loc"`
+	LastMod string   `xml:"lastmod,omitempty"`
+}
+
+// Writer streams URL entries into a single sitemap, which may be gzipped.
+// Close must be called to complete the sitemap.
+type Writer struct {
+	w  io.Writer
+	gz *gzip.Writer
+
+	// Urls written so far
+	Urls int
+
+	// Bytes written so far, before any compression
+	Bytes int
+}
+
+// NewWriter initialises a Writer, and writes the sitemap header to w
+func NewWriter(w io.Writer, gzipped bool) (*Writer, error) {
+	sw := &Writer{
+		w: w,
+	}
+
+	if gzipped {
+		sw.gz = gzip.NewWriter(w)
+		sw.w = sw.gz
+	}
+
+	return sw, sw.write([]byte(sitemapHeader))
+}
+
+// WriteUrl writes a single URL entry
+func (w *Writer) WriteUrl(u *Url) error {
+	entry, err := encodeEntry(u)
+	if err != nil {
+		return err
+	}
+
+	return w.writeEntry(entry)
+}
+
+// Close completes the sitemap, but does not close the underlying writer
+func (w *Writer) Close() error {
+	if err := w.write([]byte(sitemapFooter)); err != nil {
+		return err
+	}
+
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+
+	return nil
+}
+
+// fits determines if an encoded entry can be added without
+// exceeding maxUrls or maxBytes
+func (w *Writer) fits(entry []byte, maxUrls, maxBytes int) bool {
+	return w.Urls < maxUrls && w.Bytes+len(entry)+len(sitemapFooter) <= maxBytes
+}
+
+// writeEntry writes an already encoded URL entry
+func (w *Writer) writeEntry(entry []byte) error {
+	w.Urls++
+	return w.write(entry)
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.Bytes += n
+
+	return err
+}
+
+// WriteXMLSitemaps streams every page into as many sitemaps as are needed
+// to keep each within maxUrls URLs and maxBytes, before any compression.
+// The writer for each sitemap is obtained from create, numbered from 1,
+// and closed once the sitemap is complete. The number of sitemaps written
+// is returned, which is always at least one.
+func WriteXMLSitemaps(pages []*domain.Page, opts *Options, create func(n int) (io.WriteCloser, error), maxUrls, maxBytes int) (int, error) {
+	v := newValuer(pages, opts)
+	gzipped := opts != nil && opts.Gzip
+
+	var sw *Writer
+	var wc io.WriteCloser
+	n := 0
+
+	// Complete the current sitemap, if we have one
+	finish := func() error {
+		if sw == nil {
+			return nil
+		}
+		err := sw.Close()
+		if cerr := wc.Close(); err == nil {
+			err = cerr
+		}
+		sw = nil
+		return err
+	}
+
+	// Start the next sitemap
+	start := func() error {
+		n++
+		var err error
+		if wc, err = create(n); err != nil {
+			return err
+		}
+		sw, err = NewWriter(wc, gzipped)
+		return err
+	}
+
+	for _, p := range pages {
+		if p == nil || p.Url == nil {
+			continue
+		}
+
+		entry, err := encodeEntry(newUrl(p, v.values(p), v.opts))
+		if err != nil {
+			return n, err
+		}
+		if len(sitemapHeader)+len(entry)+len(sitemapFooter) > maxBytes {
+			return n, UrlTooLarge
+		}
+
+		// Start a new sitemap if this one is full
+		if sw != nil && !sw.fits(entry, maxUrls, maxBytes) {
+			if err := finish(); err != nil {
+				return n, err
+			}
+		}
+		if sw == nil {
+			if err := start(); err != nil {
+				return n, err
+			}
+		}
+
+		if err := sw.writeEntry(entry); err != nil {
+			return n, err
+		}
+	}
+
+	// Always write at least one sitemap, even if empty
+	if n == 0 {
+		if err := start(); err != nil {
+			return n, err
+		}
+	}
+
+	return n, finish()
+}
+
+// WriteXMLSitemapIndex writes a sitemap index referencing
+// sitemaps hosted at the specified locations
+func WriteXMLSitemapIndex(w io.Writer, locations []*url.URL) error {
+	if _, err := io.WriteString(w, sitemapIndexHeader); err != nil {
+		return err
+	}
+
+	lastmod := time.Now().UTC().Format(time.RFC3339)
+	for _, l := range locations {
+		entry, err := encodeEntry(&indexEntry{
+			Loc:     l.String(),
+			LastMod: lastmod,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(entry); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, sitemapIndexFooter)
+	return err
+}
+
+// newUrl builds the sitemap entry for a page, including any alternate
+// languages with valid codes, and its images and videos if opts asks
+// for them. The last modified time is omitted where unknown, rather
+// than guessed, as are videos without the values search engines require.
+func newUrl(p *domain.Page, v *urlValues, opts *Options) *Url {
+	u := &Url{
+		Loc:        p.Url.String(),
+		ChangeFreq: v.changeFreq,
+		Priority:   fmt.Sprintf("%.1f", v.priority),
+	}
+	if !p.LastModified.IsZero() {
+		u.LastMod = p.LastModified.UTC().Format(time.RFC3339)
+	}
+
+	for _, a := range p.Alternates {
+		if !ValidHreflang(a.Lang) {
+			continue
+		}
+		u.Alternates = append(u.Alternates, &Alternate{
+			Rel:      "alternate",
+			Hreflang: a.Lang,
+			Href:     a.Url.String(),
+		})
+	}
+
+	if opts != nil && opts.Images {
+		for _, img := range p.Images {
+			if len(u.Images) == MaxImagesPerUrl {
+				break
+			}
+			u.Images = append(u.Images, &Image{
+				Loc:     img.Url.String(),
+				Caption: img.Caption,
+				Title:   img.Title,
+			})
+		}
+	}
+
+	if opts != nil && opts.Videos {
+		for _, vid := range p.Videos {
+			if vid.Thumbnail == nil || strings.TrimSpace(vid.Title) == "" || strings.TrimSpace(vid.Description) == "" {
+				continue
+			}
+			u.Videos = append(u.Videos, &Video{
+				ThumbnailLoc: vid.Thumbnail.String(),
+				Title:        vid.Title,
+				Description:  vid.Description,
+				ContentLoc:   vid.Url.String(),
+			})
+		}
+	}
+
+	return u
+}
+
+// encodeEntry encodes a single entry as indented XML, escaping its values
+func encodeEntry(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("\t", "\t")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+
+	return buf.Bytes(), nil
+}
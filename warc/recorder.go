@@ -0,0 +1,80 @@
+package warc
+
+import (
+	"net/http"
+	"net/http/httputil"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/mattheath/kraken/domain"
+)
+
+const (
+	requestContentType  = "application/http; msgtype=request"
+	responseContentType = "application/http; msgtype=response"
+)
+
+// Recorder is a http.RoundTripper which writes every request it makes,
+// and the response received, to a WARC file. Credentials and cookies
+// are redacted, so they aren't archived.
+type Recorder struct {
+	// Transport used to make requests, http.DefaultTransport if nil
+	Transport http.RoundTripper
+
+	Writer *Writer
+}
+
+// RoundTrip makes the request and records both it and its response
+func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := rec.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	// Dump a redacted copy of the request, as transports mustn't modify
+	// requests, with a copy of its body if there is one we can get
+	dump := req.Clone(req.Context())
+	dump.Header = domain.RedactHeader(req.Header)
+	withBody := req.Body == nil || req.Body == http.NoBody
+	if !withBody && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		dump.Body = body
+		withBody = true
+	}
+	reqBlock, err := httputil.DumpRequestOut(dump, withBody)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reads the full body, leaving an unread copy in its place
+	header := res.Header
+	res.Header = domain.RedactHeader(header)
+	resBlock, err := httputil.DumpResponse(res, true)
+	res.Header = header
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+
+	target := req.URL.String()
+	resRecord := NewRecord(TypeResponse, target, responseContentType, resBlock)
+	reqRecord := NewRecord(TypeRequest, target, requestContentType, reqBlock)
+	reqRecord.Header.Set("WARC-Concurrent-To", resRecord.ID())
+
+	// Failing to archive shouldn't fail the crawl
+	for _, r := range []*Record{resRecord, reqRecord} {
+		if err := rec.Writer.WriteRecord(r); err != nil {
+			log.Errorf("Failed to write WARC %s record for %s: %v", r.Type(), target, err)
+		}
+	}
+
+	return res, nil
+}
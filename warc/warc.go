@@ -0,0 +1,196 @@
+package warc
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	version = "WARC/1.0"
+
+	// Record types we read and write
+	TypeRequest  = "request"
+	TypeResponse = "response"
+)
+
+// standardFields are written first, in this order
+var standardFields = []string{
+	"WARC-Type",
+	"WARC-Record-ID",
+	"WARC-Date",
+	"WARC-Target-URI",
+	"WARC-Concurrent-To",
+	"Content-Type",
+	"Content-Length",
+}
+
+var (
+	InvalidVersion       = errors.New("Record does not start with a WARC version line")
+	InvalidContentLength = errors.New("Record has a missing or invalid Content-Length")
+)
+
+// Record is a single WARC record, made up of named fields and a content block
+type Record struct {
+	Header  textproto.MIMEHeader
+	Content []byte
+}
+
+// NewRecord initialises a record of the specified type, with a unique ID and
+// the current date, targeted at the specified URI
+func NewRecord(recordType, targetURI, contentType string, content []byte) *Record {
+	r := &Record{
+		Header:  make(textproto.MIMEHeader),
+		Content: content,
+	}
+
+	r.Header.Set("WARC-Type", recordType)
+	r.Header.Set("WARC-Record-ID", newRecordID())
+	r.Header.Set("WARC-Date", time.Now().UTC().Format(time.RFC3339))
+	r.Header.Set("WARC-Target-URI", targetURI)
+	r.Header.Set("Content-Type", contentType)
+
+	return r
+}
+
+// Type of the record, eg. request or response
+func (r *Record) Type() string {
+	return r.Header.Get("WARC-Type")
+}
+
+// TargetURI the record was captured from
+func (r *Record) TargetURI() string {
+	return r.Header.Get("WARC-Target-URI")
+}
+
+// ID uniquely identifying the record
+func (r *Record) ID() string {
+	return r.Header.Get("WARC-Record-ID")
+}
+
+// Writer writes WARC records to an underlying writer, and is safe
+// to use from multiple goroutines
+type Writer struct {
+	sync.Mutex
+	w io.Writer
+}
+
+// NewWriter initialises and returns a new Writer
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w: w,
+	}
+}
+
+// WriteRecord writes a single record, setting its Content-Length
+func (w *Writer) WriteRecord(r *Record) error {
+	r.Header.Set("Content-Length", strconv.Itoa(len(r.Content)))
+
+	w.Lock()
+	defer w.Unlock()
+
+	bw := bufio.NewWriter(w.w)
+	fmt.Fprintf(bw, "%s\r\n", version)
+	for _, k := range fieldOrder(r.Header) {
+		for _, v := range r.Header[textproto.CanonicalMIMEHeaderKey(k)] {
+			fmt.Fprintf(bw, "%s: %s\r\n", k, v)
+		}
+	}
+	bw.WriteString("\r\n")
+	bw.Write(r.Content)
+	bw.WriteString("\r\n\r\n")
+
+	return bw.Flush()
+}
+
+// Reader reads WARC records from an underlying reader
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader initialises and returns a new Reader
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		r: bufio.NewReader(r),
+	}
+}
+
+// ReadRecord returns the next record, or io.EOF when there are none left
+func (r *Reader) ReadRecord() (*Record, error) {
+	tp := textproto.NewReader(r.r)
+
+	// Skip any blank lines left between records
+	var line string
+	var err error
+	for line == "" {
+		line, err = tp.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if line != version {
+		return nil, InvalidVersion
+	}
+
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, InvalidContentLength
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r.r, content); err != nil {
+		return nil, err
+	}
+
+	return &Record{
+		Header:  header,
+		Content: content,
+	}, nil
+}
+
+// fieldOrder returns the names of the fields in a header, with the
+// standard WARC fields first and in their usual spelling, as
+// textproto canonicalises them to eg. Warc-Record-Id
+func fieldOrder(header textproto.MIMEHeader) []string {
+	ret := make([]string, 0, len(header))
+	seen := make(map[string]bool)
+
+	for _, k := range standardFields {
+		if _, ok := header[textproto.CanonicalMIMEHeaderKey(k)]; ok {
+			ret = append(ret, k)
+			seen[textproto.CanonicalMIMEHeaderKey(k)] = true
+		}
+	}
+
+	others := make([]string, 0)
+	for k := range header {
+		if !seen[k] {
+			others = append(others, k)
+		}
+	}
+	sort.Strings(others)
+
+	return append(ret, others...)
+}
+
+// newRecordID generates a random (version 4) UUID URN for a record
+func newRecordID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
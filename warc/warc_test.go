@@ -0,0 +1,153 @@
+package warc
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteReadRecords(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	testCases := []*Record{
+		NewRecord(TypeResponse, "http://example.com/", responseContentType, []byte("HTTP/1.1 200 OK\r\n\r\nhello")),
+		NewRecord(TypeRequest, "http://example.com/", requestContentType, []byte("GET / HTTP/1.1\r\n\r\n")),
+		NewRecord(TypeResponse, "http://example.com/empty", responseContentType, []byte{}),
+	}
+
+	for _, r := range testCases {
+		assert.Nil(t, w.WriteRecord(r))
+	}
+
+	// Standard fields should keep their usual spelling
+	assert.True(t, strings.HasPrefix(buf.String(), "WARC/1.0\r\nWARC-Type: response\r\nWARC-Record-ID: <urn:uuid:"))
+
+	r := NewReader(&buf)
+	for _, expected := range testCases {
+		rec, err := r.ReadRecord()
+		assert.Nil(t, err)
+		assert.Equal(t, expected.Type(), rec.Type())
+		assert.Equal(t, expected.ID(), rec.ID())
+		assert.Equal(t, expected.TargetURI(), rec.TargetURI())
+		assert.Equal(t, expected.Content, rec.Content)
+	}
+
+	_, err := r.ReadRecord()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadInvalidRecord(t *testing.T) {
+	testCases := map[string]error{
+		"HTTP/1.1 200 OK\r\n\r\n":                         InvalidVersion,
+		"WARC/1.0\r\nWARC-Type: response\r\n\r\n":         InvalidContentLength,
+		"WARC/1.0\r\nContent-Length: nope\r\n\r\n":        InvalidContentLength,
+		"WARC/1.0\r\nContent-Length: 10\r\n\r\ntoo short": io.ErrUnexpectedEOF,
+	}
+
+	for tc, expected := range testCases {
+		_, err := NewReader(strings.NewReader(tc)).ReadRecord()
+		assert.Equal(t, expected, err, tc)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Kraken!"))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := &http.Client{
+		Transport: &Recorder{
+			Writer: NewWriter(&buf),
+		},
+	}
+
+	// The caller should still receive the full body
+	res, err := client.Get(server.URL + "/page")
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "Kraken!", string(body))
+
+	r := NewReader(&buf)
+	resRecord, err := r.ReadRecord()
+	assert.Nil(t, err)
+	assert.Equal(t, TypeResponse, resRecord.Type())
+	assert.Equal(t, server.URL+"/page", resRecord.TargetURI())
+	assert.True(t, bytes.HasSuffix(resRecord.Content, []byte("Kraken!")))
+
+	reqRecord, err := r.ReadRecord()
+	assert.Nil(t, err)
+	assert.Equal(t, TypeRequest, reqRecord.Type())
+	assert.Equal(t, resRecord.ID(), reqRecord.Header.Get("WARC-Concurrent-To"))
+	assert.True(t, bytes.HasPrefix(reqRecord.Content, []byte("GET /page HTTP/1.1")))
+}
+
+func TestRecorderRedactsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer s3cret", r.Header.Get("Authorization"))
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		w.Write([]byte("Kraken!"))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := &http.Client{
+		Transport: &Recorder{
+			Writer: NewWriter(&buf),
+		},
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+"/page", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	res, err := client.Do(req)
+	assert.Nil(t, err)
+	res.Body.Close()
+
+	// The caller still receives the cookie, but the archive doesn't
+	assert.Equal(t, "abc", res.Cookies()[0].Value)
+	assert.NotContains(t, buf.String(), "s3cret")
+	assert.NotContains(t, buf.String(), "session=abc")
+	assert.Contains(t, buf.String(), "Authorization: [redacted]")
+	assert.Contains(t, buf.String(), "Set-Cookie: [redacted]")
+
+	// The caller's request is left as it was
+	assert.Equal(t, "Bearer s3cret", req.Header.Get("Authorization"))
+}
+
+func TestRecorderRequestBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := &http.Client{
+		Transport: &Recorder{
+			Writer: NewWriter(&buf),
+		},
+	}
+
+	// Bodies are both sent and recorded
+	res, err := client.Post(server.URL, "text/plain", strings.NewReader("Kraken!"))
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "Kraken!", string(body))
+
+	r := NewReader(&buf)
+	r.ReadRecord()
+	reqRecord, err := r.ReadRecord()
+	assert.Nil(t, err)
+	assert.True(t, bytes.HasSuffix(reqRecord.Content, []byte("Kraken!")))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/mattheath/kraken/crawler"
+	"github.com/mattheath/kraken/domain"
+	"github.com/mattheath/kraken/warc"
+)
+
+// maxRedirects we follow before giving up, matching http.Client
+const maxRedirects = 10
+
+var (
+	NotArchived       = errors.New("URL is not in the archive")
+	TooManyRedirects  = errors.New("Stopped after too many redirects")
+	InvalidRedirectTo = errors.New("Redirect has no valid Location")
+)
+
+// WarcFetcher replays responses from a WARC file, so a crawl can be
+// repeated exactly without touching the network
+type WarcFetcher struct {
+	extractor
+
+	// responses holds the raw HTTP response recorded for each URL
+	responses map[string][]byte
+}
+
+// NewWarcFetcher reads every response record from a WARC file into a new
+// WarcFetcher. Where a URL was recorded more than once the last wins.
+func NewWarcFetcher(r io.Reader) (*WarcFetcher, error) {
+	f := &WarcFetcher{
+		responses: make(map[string][]byte),
+	}
+
+	wr := warc.NewReader(r)
+	for {
+		rec, err := wr.ReadRecord()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if rec.Type() != warc.TypeResponse {
+			continue
+		}
+		f.responses[rec.TargetURI()] = rec.Content
+	}
+
+	log.Debugf("Loaded %v responses from WARC", len(f.responses))
+
+	return f, nil
+}
+
+// Fetch replays the archived response for the specified URL, following
+// any archived redirects, and extracts URLs
+func (f *WarcFetcher) Fetch(target *url.URL) (*domain.Page, error) {
+
+	res, err := f.follow(target)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	page, err := f.read(res.Body, res.Request.URL, res.Header)
+	if err != nil {
+		return nil, err
+	}
+	page.Status = res.StatusCode
+	page.RedirectStatus = redirectStatus(res)
+
+	return page, nil
+}
+
+// Retrieve returns the archived body for the specified URL, which
+// must have responded OK
+func (f *WarcFetcher) Retrieve(target *url.URL) (io.ReadCloser, error) {
+
+	res, err := f.follow(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("Retrieving %s failed with status %v", target, res.StatusCode)
+	}
+
+	return res.Body, nil
+}
+
+// follow archived redirects from the specified URL, as http.Client would
+// have done when recording, returning the final response along with
+// the redirect which led to it, as http.Client does
+func (f *WarcFetcher) follow(target *url.URL) (*http.Response, error) {
+
+	current := target
+	var prev *http.Response
+	visited := make(map[string]bool)
+	for i := 0; i <= maxRedirects; i++ {
+		if visited[current.String()] {
+			return nil, crawler.RedirectLoop
+		}
+		visited[current.String()] = true
+
+		res, err := f.response(current)
+		if err != nil {
+			return nil, err
+		}
+		res.Request.Response = prev
+
+		if res.StatusCode < 300 || res.StatusCode >= 400 {
+			return res, nil
+		}
+
+		res.Body.Close()
+		prev = res
+		loc, err := res.Location()
+		if err != nil {
+			return nil, InvalidRedirectTo
+		}
+		current = loc
+	}
+
+	return nil, TooManyRedirects
+}
+
+// response parses the archived response for a URL
+func (f *WarcFetcher) response(target *url.URL) (*http.Response, error) {
+	block, ok := f.responses[target.String()]
+	if !ok {
+		return nil, NotArchived
+	}
+
+	req := &http.Request{
+		Method: "GET",
+		URL:    target,
+	}
+
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(block)), req)
+}
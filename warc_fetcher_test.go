@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattheath/kraken/crawler"
+	"github.com/mattheath/kraken/warc"
+)
+
+// recordSite crawls a test server serving fakeSite, recording it to a WARC,
+// and returns the pages found along with the archive
+func recordSite(t *testing.T) (string, []string, []byte) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/about", http.StatusMovedPermanently)
+		case "/":
+			fmt.Fprint(w, fakeSite["index.html"]+`<a href="/old">Old</a>`)
+		case "/about":
+			fmt.Fprint(w, fakeSite["about.html"])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	fetcher := &HttpFetcher{
+		Client: &http.Client{
+			Transport: &warc.Recorder{
+				Writer: warc.NewWriter(&buf),
+			},
+		},
+	}
+
+	c := crawler.NewCrawler()
+	c.Work(strToUrl(server.URL+"/"), 3, fetcher)
+
+	return server.URL, pageUrls(c), buf.Bytes()
+}
+
+func TestWarcFetcherReplay(t *testing.T) {
+	base, _, archive := recordSite(t)
+
+	f, err := NewWarcFetcher(bytes.NewReader(archive))
+	assert.Nil(t, err)
+
+	// The server has gone, so everything must come from the archive
+	page, err := f.Fetch(strToUrl(base + "/"))
+	assert.Nil(t, err)
+	links := linksToStrings(page.Links)
+	sort.Strings(links)
+	assert.Equal(t, []string{base + "/about", base + "/docs/", base + "/old"}, links)
+	assert.Equal(t, http.StatusOK, page.Status)
+	assert.Contains(t, page.Header.Get("Content-Type"), "text/html")
+
+	// Along with the status of pages which weren't found
+	page, err = f.Fetch(strToUrl(base + "/docs/"))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, page.Status)
+
+	// Redirects are followed within the archive
+	page, err = f.Fetch(strToUrl(base + "/old"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{base + "/"}, linksToStrings(page.Links))
+	assert.Equal(t, []string{base + "/logo.png"}, assetsToStrings(page.Assets))
+	assert.Equal(t, http.StatusOK, page.Status)
+	assert.Equal(t, http.StatusMovedPermanently, page.RedirectStatus)
+
+	_, err = f.Fetch(strToUrl(base + "/never-crawled"))
+	assert.Equal(t, NotArchived, err)
+}
+
+func TestWarcFetcherCrawl(t *testing.T) {
+	base, recorded, archive := recordSite(t)
+
+	f, err := NewWarcFetcher(bytes.NewReader(archive))
+	assert.Nil(t, err)
+
+	// Replaying gives the same crawl as when recorded
+	c := crawler.NewCrawler()
+	c.Work(strToUrl(base+"/"), 3, f)
+
+	assert.Equal(t, recorded, pageUrls(c))
+	assert.Contains(t, recorded, base+"/about")
+}
+
+// pageUrls returns the sorted URLs of all pages a crawler found
+func pageUrls(c crawler.Crawler) []string {
+	ret := make([]string, 0)
+	for _, p := range c.AllPages() {
+		ret = append(ret, p.Url.String())
+	}
+	sort.Strings(ret)
+
+	return ret
+}